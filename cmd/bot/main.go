@@ -26,13 +26,20 @@ var (
 	configPath = flag.String("config", "config/config.yaml", "Path to configuration file")
 )
 
-func displayMenu() int {
+func displayMenu(dryRun bool) int {
+	dryRunState := "OFF"
+	if dryRun {
+		dryRunState = "ON"
+	}
 
 	fmt.Println("\n🚀 Token-2022 Bot Menu")
 	fmt.Println("1 - Start Bot")
 	fmt.Println("2 - Check Wallet")
 	fmt.Println("3 - Check Dividends")
 	fmt.Println("4 - Analytics")
+	fmt.Printf("5 - Dry Run Mode [%s]\n", dryRunState)
+	fmt.Println("6 - Export CSV")
+	fmt.Println("7 - Release Held Proceeds")
 	fmt.Println("0 - Exit")
 	fmt.Print("\nSelect an option: ")
 
@@ -80,7 +87,7 @@ func main() {
 	}
 
 	for {
-		choice := displayMenu()
+		choice := displayMenu(cfg.Trading.DryRun)
 
 		switch choice {
 		case 0:
@@ -92,16 +99,46 @@ func main() {
 			// Display settings and get confirmation
 			fmt.Println("\n📊 Bot Settings:")
 			fmt.Printf("Input Token: %s\n", cfg.Token.InputMint)
-			fmt.Printf("Output Token: %s\n", cfg.Token.OutputMint)
+			if len(cfg.Token.Targets) > 1 {
+				fmt.Println("Target Tokens:")
+				for _, target := range cfg.Token.Targets {
+					fmt.Printf("  %s (weight %.2f)\n", target.Mint, target.Weight)
+				}
+			} else {
+				fmt.Printf("Output Token: %s\n", cfg.Token.OutputMint)
+			}
 			if cfg.Token.DividendMint != "" {
 				fmt.Printf("Dividend Token: %s\n", cfg.Token.DividendMint)
 			}
-			fmt.Printf("Swap Amount: %.2f\n", cfg.Token.SwapAmount)
+			sizing := cfg.RefillSizing()
+			fmt.Printf("Sizing Strategy: %s\n", sizing.SizingStrategy)
+			switch sizing.SizingStrategy {
+			case "fixed":
+				fmt.Printf("Swap Amount: %.2f\n", sizing.SwapAmount)
+			case "percent":
+				fmt.Printf("Surplus Percent: %.0f%%\n", sizing.SurplusPercent)
+			}
+			if sizing.MaxSwapAmount > 0 {
+				fmt.Printf("Max Swap Amount: %.2f\n", sizing.MaxSwapAmount)
+			}
 			fmt.Printf("Slippage: %.2f%%\n", float64(cfg.Token.SlippageBPS)/100)
-			fmt.Printf("Min SOL Balance: %.2f\n", cfg.Wallet.MinSolBalance)
+			if cfg.Monitor.WatchMint != "" {
+				fmt.Printf("Watched Token: %s (trigger at %.4f, reserve %.4f)\n", cfg.Monitor.WatchMint, cfg.Monitor.MinTokenBalance, cfg.Monitor.TokenReserveAmount)
+				fmt.Printf("Min Fee Balance: %.4f SOL\n", cfg.Wallet.MinFeeBalance)
+			} else {
+				fmt.Printf("Min SOL Balance: %.2f\n", cfg.Wallet.MinSolBalance)
+			}
 			fmt.Printf("Reserve Amount: %.2f\n", cfg.Wallet.ReserveAmount)
 			fmt.Printf("Check Interval: %d minutes\n", cfg.Monitor.CheckIntervalMinutes)
+			fmt.Printf("Buy Mode: %s\n", cfg.Monitor.Mode)
+			switch cfg.Monitor.Mode {
+			case "schedule", "both":
+				fmt.Printf("Schedule: %s (%.4f per run, catch up: %s)\n", cfg.Monitor.Schedule, cfg.Monitor.ScheduleAmount, cfg.Monitor.CatchUp)
+			case "dividend":
+				fmt.Printf("Dividend Address: %s\n", cfg.Token.DividendMint)
+			}
 			fmt.Printf("Direct Routes Only: %v\n", cfg.Jupiter.OnlyDirectRoutes)
+			fmt.Printf("Dry Run: %v\n", cfg.Trading.DryRun)
 
 			fmt.Print("\nDo you want to start the bot with these settings? (y/n): ")
 			reader := bufio.NewReader(os.Stdin)
@@ -193,8 +230,72 @@ func main() {
 			}
 		case 4:
 			utils.Info("Opening analytics...")
-			// TODO: Implement analytics
-			fmt.Println("Analytics functionality coming soon!")
+
+			// Paper trades are journaled apart, follow the dry run toggle
+			walletAddr := solana.MustPrivateKeyFromBase58(cfg.Wallet.PrivateKey).PublicKey().String()
+			if err := bot.DisplayStats(cfg, walletAddr, cfg.Trading.DryRun); err != nil {
+				utils.Error("Failed to display analytics", err)
+				continue
+			}
+
+			rpcClient := rpc.New(cfg.RPC.Endpoint)
+			tokenClient := token2022.NewClient(cfg, rpcClient)
+			if err := bot.DisplayPnL(context.Background(), cfg, rpcClient, tokenClient, walletAddr, cfg.Trading.DryRun); err != nil {
+				utils.Error("Failed to display profit and loss", err)
+			}
+		case 5:
+			cfg.Trading.DryRun = !cfg.Trading.DryRun
+			utils.Info("Dry run mode toggled", "dry_run", cfg.Trading.DryRun)
+			if cfg.Trading.DryRun {
+				fmt.Println("🧪 Dry run enabled: swaps are simulated against a virtual balance")
+			} else {
+				fmt.Println("💸 Dry run disabled: swaps will spend real funds")
+			}
+		case 6:
+			utils.Info("Exporting trades and dividends...")
+
+			walletAddr := solana.MustPrivateKeyFromBase58(cfg.Wallet.PrivateKey).PublicKey().String()
+			paths, err := bot.ExportCSV(cfg, walletAddr, bot.ExportFormats)
+			if err != nil {
+				utils.Error("Failed to export CSV", err)
+				continue
+			}
+
+			fmt.Println("\n📄 Exported:")
+			for _, path := range paths {
+				fmt.Printf("  • %s\n", path)
+			}
+			fmt.Println()
+		case 7:
+			walletAddr := solana.MustPrivateKeyFromBase58(cfg.Wallet.PrivateKey).PublicKey().String()
+			book, err := bot.LoadCostBasisBook(walletAddr, cfg.Trading.DryRun)
+			if err != nil {
+				utils.Error("Failed to load cost basis", err)
+				continue
+			}
+
+			locked := book.Locked()
+			if locked == 0 {
+				fmt.Println("No exit proceeds are held back")
+				continue
+			}
+
+			fmt.Printf("\n🔒 Held exit proceeds: %.6f\n", locked)
+			fmt.Print("Release them so refills can swap them again? (y/n): ")
+			reader := bufio.NewReader(os.Stdin)
+			confirm, _ := reader.ReadString('\n')
+			confirm = strings.TrimSpace(strings.ToLower(confirm))
+			if confirm != "y" && confirm != "yes" {
+				fmt.Println("Release cancelled")
+				continue
+			}
+
+			released, err := book.Release()
+			if err != nil {
+				utils.Error("Failed to release held proceeds", err)
+				continue
+			}
+			utils.Info("Held exit proceeds released", "amount", released, "paper", cfg.Trading.DryRun)
 		default:
 			fmt.Println("Invalid option, please try again")
 		}
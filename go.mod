@@ -36,6 +36,9 @@ require (
 )
 
 require (
+	github.com/buger/jsonparser v1.1.1 // indirect
+	github.com/gorilla/rpc v1.2.0 // indirect
+	github.com/gorilla/websocket v1.4.2 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
 	golang.org/x/crypto v0.26.0 // indirect
@@ -0,0 +1,203 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/magooney-loon/token-2022-refill-bot/internal/config"
+	"github.com/magooney-loon/token-2022-refill-bot/internal/utils"
+	"github.com/magooney-loon/token-2022-refill-bot/pkg/token2022"
+	"github.com/magooney-loon/token-2022-refill-bot/pkg/wallet"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// minAllocationShare drops allocations smaller than this share of the refill,
+// so one refill doesn't turn into several dust swaps
+const minAllocationShare = 0.05
+
+// Allocation is the part of a refill assigned to one target token
+type Allocation struct {
+	Mint         string
+	Amount       float64
+	CurrentShare float64
+	TargetShare  float64
+}
+
+// Allocator splits each refill among the target tokens by their portfolio weights
+type Allocator struct {
+	config      *config.Config
+	rpcClient   *rpc.Client
+	tokenClient *token2022.Client
+	wallet      solana.PublicKey
+	ledger      *PaperLedger
+}
+
+// NewAllocator creates a new allocator for the configured targets
+func NewAllocator(cfg *config.Config, rpcClient *rpc.Client, tokenClient *token2022.Client, wallet solana.PublicKey) *Allocator {
+	return &Allocator{
+		config:      cfg,
+		rpcClient:   rpcClient,
+		tokenClient: tokenClient,
+		wallet:      wallet,
+	}
+}
+
+// SetPaperLedger makes the allocator weigh the ledger's virtual holdings
+func (a *Allocator) SetPaperLedger(ledger *PaperLedger) {
+	a.ledger = ledger
+}
+
+// Allocate splits amount of inputMint among the targets, giving the most
+// to whichever targets are furthest below their weight after the refill
+func (a *Allocator) Allocate(ctx context.Context, inputMint string, amount float64) ([]Allocation, error) {
+	targets := a.config.Token.Targets
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no target tokens configured")
+	}
+	if len(targets) == 1 {
+		return []Allocation{{Mint: targets[0].Mint, Amount: amount, TargetShare: 1}}, nil
+	}
+
+	totalWeight := 0.0
+	for _, target := range targets {
+		totalWeight += target.Weight
+	}
+
+	values, inputPrice, err := a.targetValues(ctx, inputMint)
+	if err != nil || inputPrice <= 0 {
+		// Without prices we can't measure drift, so split by weight alone
+		utils.Warn("Failed to value portfolio, splitting refill by weight", "error", err)
+		allocations := make([]Allocation, 0, len(targets))
+		for _, target := range targets {
+			allocations = append(allocations, Allocation{
+				Mint:        target.Mint,
+				Amount:      amount * target.Weight / totalWeight,
+				TargetShare: target.Weight / totalWeight,
+			})
+		}
+		return allocations, nil
+	}
+
+	currentTotal := 0.0
+	for _, value := range values {
+		currentTotal += value
+	}
+	refillValue := amount * inputPrice
+	totalAfter := currentTotal + refillValue
+
+	// Each target's shortfall from its weight once the refill is invested
+	allocations := make([]Allocation, 0, len(targets))
+	totalDeficit := 0.0
+	for _, target := range targets {
+		share := target.Weight / totalWeight
+		current := values[target.Mint]
+		deficit := share*totalAfter - current
+		if deficit < 0 {
+			deficit = 0
+		}
+		totalDeficit += deficit
+
+		currentShare := 0.0
+		if currentTotal > 0 {
+			currentShare = current / currentTotal
+		}
+		allocations = append(allocations, Allocation{
+			Mint:         target.Mint,
+			Amount:       deficit,
+			CurrentShare: currentShare,
+			TargetShare:  share,
+		})
+	}
+
+	for i := range allocations {
+		allocations[i].Amount = amount * allocations[i].Amount / totalDeficit
+	}
+
+	allocations = dropDust(allocations, amount)
+
+	// Most underweight first
+	sort.Slice(allocations, func(i, j int) bool {
+		return allocations[i].TargetShare-allocations[i].CurrentShare >
+			allocations[j].TargetShare-allocations[j].CurrentShare
+	})
+
+	for _, allocation := range allocations {
+		utils.Debug("Refill allocation",
+			"mint", allocation.Mint,
+			"amount", allocation.Amount,
+			"current_share", fmt.Sprintf("%.2f%%", allocation.CurrentShare*100),
+			"target_share", fmt.Sprintf("%.2f%%", allocation.TargetShare*100))
+	}
+
+	return allocations, nil
+}
+
+// targetValues returns the USD value held in each target and inputMint's USD price
+func (a *Allocator) targetValues(ctx context.Context, inputMint string) (map[string]float64, float64, error) {
+	holdings, err := a.holdings(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	mints := []string{inputMint}
+	for _, target := range a.config.Token.Targets {
+		mints = append(mints, target.Mint)
+	}
+
+	prices, err := wallet.GetTokenPrices(ctx, a.config, mints)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get token prices: %w", err)
+	}
+
+	values := make(map[string]float64)
+	for _, target := range a.config.Token.Targets {
+		values[target.Mint] = holdings[target.Mint] * prices[target.Mint]
+	}
+
+	return values, prices[inputMint], nil
+}
+
+// holdings returns the wallet's balance of each mint, virtual in dry-run mode
+func (a *Allocator) holdings(ctx context.Context) (map[string]float64, error) {
+	holdings := make(map[string]float64)
+
+	if a.ledger != nil {
+		for _, target := range a.config.Token.Targets {
+			holdings[target.Mint] = a.ledger.Balance(target.Mint)
+		}
+		return holdings, nil
+	}
+
+	balances, err := wallet.GetWalletBalances(ctx, a.config, a.rpcClient, a.wallet.String(), a.tokenClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get wallet balances: %w", err)
+	}
+	for _, balance := range balances {
+		holdings[balance.Mint] += balance.Balance
+	}
+	return holdings, nil
+}
+
+// dropDust removes allocations below the minimum share and hands their amount
+// to the remaining targets in proportion
+func dropDust(allocations []Allocation, amount float64) []Allocation {
+	kept := make([]Allocation, 0, len(allocations))
+	keptTotal := 0.0
+	for _, allocation := range allocations {
+		if allocation.Amount >= amount*minAllocationShare {
+			kept = append(kept, allocation)
+			keptTotal += allocation.Amount
+		}
+	}
+	if len(kept) == 0 || keptTotal <= 0 {
+		return kept
+	}
+
+	for i := range kept {
+		kept[i].Amount = amount * kept[i].Amount / keptTotal
+	}
+	return kept
+}
@@ -0,0 +1,271 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/magooney-loon/token-2022-refill-bot/internal/config"
+)
+
+// heartbeatInterval is how often a running bot records that it is still up
+const heartbeatInterval = time.Minute
+
+// TokenStats sums up the buys of one target token
+type TokenStats struct {
+	Mint           string
+	Symbol         string
+	Buys           int64
+	Bought         float64 // Tokens received
+	Spent          float64 // Input token spent
+	SpentUSD       float64
+	AverageCost    float64 // Input token per token bought
+	AverageCostUSD float64
+}
+
+// RunSession is one period the bot was running
+type RunSession struct {
+	Start    time.Time `json:"start"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// RunLog records when the bot was running, to report its uptime
+type RunLog struct {
+	mu   sync.Mutex
+	path string
+
+	Sessions []RunSession `json:"sessions"`
+}
+
+// RunLogPath returns where a wallet's run sessions are kept, next to its trade journal
+func RunLogPath(dir, wallet string, paper bool) string {
+	name := fmt.Sprintf("runs_%s.json", wallet[:8])
+	if paper {
+		name = fmt.Sprintf("runs_paper_%s.json", wallet[:8])
+	}
+	return filepath.Join(dir, name)
+}
+
+// LoadRunLog loads the wallet's run sessions, a missing file has none
+func LoadRunLog(dir, wallet string, paper bool) (*RunLog, error) {
+	log := &RunLog{path: RunLogPath(dir, wallet, paper)}
+
+	data, err := os.ReadFile(log.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return log, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, log); err != nil {
+		return nil, fmt.Errorf("failed to parse run log: %w", err)
+	}
+	return log, nil
+}
+
+// Begin records the start of a new session
+func (r *RunLog) Begin(now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Sessions = append(r.Sessions, RunSession{Start: now, LastSeen: now})
+	return r.save()
+}
+
+// Heartbeat extends the current session up to now
+func (r *RunLog) Heartbeat(now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.Sessions) == 0 {
+		return nil
+	}
+	r.Sessions[len(r.Sessions)-1].LastSeen = now
+	return r.save()
+}
+
+// Uptime returns when the latest session started, the total time running, and the share
+// of the time since the first session the bot was running
+func (r *RunLog) Uptime(now time.Time) (time.Time, time.Duration, float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.Sessions) == 0 {
+		return time.Time{}, 0, 0
+	}
+
+	var running time.Duration
+	for _, session := range r.Sessions {
+		running += session.LastSeen.Sub(session.Start)
+	}
+
+	latest := r.Sessions[len(r.Sessions)-1].Start
+	tracked := now.Sub(r.Sessions[0].Start)
+	if tracked <= 0 {
+		return latest, running, 100
+	}
+	return latest, running, min(float64(running)/float64(tracked)*100, 100)
+}
+
+// save writes the sessions to disk, the caller must hold the lock
+func (r *RunLog) save() error {
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write to a temp file first so a crash can't corrupt the sessions
+	tmp := r.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, r.path)
+}
+
+// ComputeStats sums up journaled swaps. Buys are swaps out of inputMint, slippage is the
+// realized output shortfall against the quote and only counts swaps that went on chain.
+func ComputeStats(entries []JournalEntry, inputMint string) BotStats {
+	stats := BotStats{Tokens: make(map[string]*TokenStats)}
+
+	var slippageSum float64
+	var slippageCount int
+	for _, entry := range entries {
+		stats.TotalSwaps++
+		if !entry.Succeeded() {
+			stats.FailedSwaps++
+			continue
+		}
+		stats.SuccessfulSwaps++
+		stats.TotalFees += entry.Fee
+		stats.TotalVolume += entry.InputAmount * entry.InputPriceUSD
+
+		if !entry.DryRun && entry.QuotedOutput > 0 {
+			slippage := (entry.QuotedOutput - entry.OutputAmount) / entry.QuotedOutput * 100
+			slippageSum += slippage
+			slippageCount++
+			if slippageCount == 1 || slippage > stats.HighestSlippage {
+				stats.HighestSlippage = slippage
+			}
+		}
+
+		if entry.InputMint != inputMint {
+			continue
+		}
+		token, ok := stats.Tokens[entry.OutputMint]
+		if !ok {
+			token = &TokenStats{Mint: entry.OutputMint}
+			stats.Tokens[entry.OutputMint] = token
+		}
+		if entry.OutputSymbol != "" {
+			token.Symbol = entry.OutputSymbol
+		}
+		token.Buys++
+		token.Bought += entry.OutputAmount
+		token.Spent += entry.InputAmount
+		token.SpentUSD += entry.InputAmount * entry.InputPriceUSD
+	}
+
+	for _, token := range stats.Tokens {
+		if token.Bought > 0 {
+			token.AverageCost = token.Spent / token.Bought
+			token.AverageCostUSD = token.SpentUSD / token.Bought
+		}
+	}
+
+	if slippageCount > 0 {
+		stats.AverageSlippage = slippageSum / float64(slippageCount)
+	}
+	if stats.SuccessfulSwaps > 0 {
+		stats.AverageFee = stats.TotalFees / float64(stats.SuccessfulSwaps)
+	}
+	if stats.TotalSwaps > 0 {
+		stats.SuccessRate = float64(stats.SuccessfulSwaps) / float64(stats.TotalSwaps) * 100
+	}
+
+	return stats
+}
+
+// LoadStats computes a wallet's statistics from its trade journal and run log
+func LoadStats(cfg *config.Config, wallet string, paper bool) (BotStats, error) {
+	entries, err := ReadJournal(JournalPath(cfg.Trading.JournalDir, wallet, paper))
+	if err != nil {
+		return BotStats{}, fmt.Errorf("failed to read trade journal: %w", err)
+	}
+
+	runs, err := LoadRunLog(cfg.Trading.JournalDir, wallet, paper)
+	if err != nil {
+		return BotStats{}, err
+	}
+
+	stats := ComputeStats(entries, cfg.Token.InputMint)
+	stats.StartTime, stats.Uptime, stats.UptimePercentage = runs.Uptime(time.Now())
+	return stats, nil
+}
+
+// DisplayStats shows a wallet's statistics in a formatted UI
+func DisplayStats(cfg *config.Config, wallet string, paper bool) error {
+	stats, err := LoadStats(cfg, wallet, paper)
+	if err != nil {
+		return err
+	}
+
+	title := "📈 Analytics"
+	if paper {
+		title += " (dry run)"
+	}
+	fmt.Printf("\n%s for %s\n", title, wallet[:8]+"..."+wallet[len(wallet)-8:])
+	fmt.Println("-------------------")
+
+	if stats.TotalSwaps == 0 {
+		fmt.Println("• No trades journaled yet")
+		fmt.Println()
+		return nil
+	}
+
+	fmt.Printf("• Swaps: %d (%d succeeded, %d failed)\n", stats.TotalSwaps, stats.SuccessfulSwaps, stats.FailedSwaps)
+	fmt.Printf("• Success Rate: %.2f%%\n", stats.SuccessRate)
+	fmt.Printf("• Total Volume: $%.2f\n", stats.TotalVolume)
+	fmt.Printf("• Slippage vs Quote: %.4f%% average, %.4f%% highest\n", stats.AverageSlippage, stats.HighestSlippage)
+	fmt.Printf("• Fees: %.9f SOL total, %.9f SOL average\n", stats.TotalFees, stats.AverageFee)
+	if !stats.StartTime.IsZero() {
+		fmt.Printf("• Last Started: %s\n", stats.StartTime.Format("2006-01-02 15:04:05"))
+		fmt.Printf("• Uptime: %s (%.2f%%)\n", stats.Uptime.Round(time.Minute), stats.UptimePercentage)
+	}
+
+	if len(stats.Tokens) > 0 {
+		fmt.Println("\n🎯 Average Cost per Token:")
+
+		tokens := make([]*TokenStats, 0, len(stats.Tokens))
+		for _, token := range stats.Tokens {
+			tokens = append(tokens, token)
+		}
+		sort.Slice(tokens, func(i, j int) bool { return tokens[i].SpentUSD > tokens[j].SpentUSD })
+
+		for _, token := range tokens {
+			name := token.Symbol
+			if name == "" {
+				name = token.Mint[:8] + "..."
+			}
+			fmt.Printf("  • %s: %d buys, %.6f bought for %.6f ($%.2f) | %.9f per token ($%.6f)\n",
+				name,
+				token.Buys,
+				token.Bought,
+				token.Spent,
+				token.SpentUSD,
+				token.AverageCost,
+				token.AverageCostUSD)
+		}
+	}
+
+	fmt.Println()
+	return nil
+}
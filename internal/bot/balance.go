@@ -0,0 +1,104 @@
+package bot
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// WatchedAccount is the account holding the wallet's balance of a mint
+type WatchedAccount struct {
+	Mint     string
+	Address  solana.PublicKey
+	Decimals uint8
+	Native   bool
+}
+
+// ResolveWatchedAccount finds where owner holds mint: the wallet itself for SOL,
+// otherwise its associated token account under whichever token program owns the mint
+func ResolveWatchedAccount(ctx context.Context, rpcClient *rpc.Client, owner solana.PublicKey, mint string) (*WatchedAccount, error) {
+	if mint == "" || mint == NativeMint {
+		return &WatchedAccount{Mint: NativeMint, Address: owner, Decimals: 9, Native: true}, nil
+	}
+
+	mintKey, err := solana.PublicKeyFromBase58(mint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mint %s: %w", mint, err)
+	}
+
+	info, err := rpcClient.GetAccountInfo(ctx, mintKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mint account %s: %w", mint, err)
+	}
+
+	program := info.Value.Owner
+	if !program.Equals(solana.TokenProgramID) && !program.Equals(solana.Token2022ProgramID) {
+		return nil, fmt.Errorf("mint %s is not owned by a token program", mint)
+	}
+
+	// Decimals follow the mint authority option and supply
+	data := info.Value.Data.GetBinary()
+	if len(data) < 45 {
+		return nil, fmt.Errorf("mint %s data too short", mint)
+	}
+
+	ata, _, err := solana.FindProgramAddress([][]byte{
+		owner[:],
+		program[:],
+		mintKey[:],
+	}, solana.SPLAssociatedTokenAccountProgramID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive token account: %w", err)
+	}
+
+	return &WatchedAccount{Mint: mint, Address: ata, Decimals: data[44]}, nil
+}
+
+// Fetch reads the raw balance; a token account that doesn't exist yet holds nothing
+func (w *WatchedAccount) Fetch(ctx context.Context, rpcClient *rpc.Client, commitment rpc.CommitmentType) (uint64, error) {
+	if w.Native {
+		balance, err := rpcClient.GetBalance(ctx, w.Address, commitment)
+		if err != nil {
+			return 0, err
+		}
+		return balance.Value, nil
+	}
+
+	info, err := rpcClient.GetAccountInfoWithOpts(ctx, w.Address, &rpc.GetAccountInfoOpts{
+		Commitment: commitment,
+	})
+	if errors.Is(err, rpc.ErrNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return w.Amount(info.Value)
+}
+
+// Amount reads the raw balance from the account's state
+func (w *WatchedAccount) Amount(account *rpc.Account) (uint64, error) {
+	if account == nil {
+		return 0, nil
+	}
+	if w.Native {
+		return account.Lamports, nil
+	}
+
+	// Token account layout: mint (32), owner (32), amount (8)
+	data := account.Data.GetBinary()
+	if len(data) < 72 {
+		return 0, fmt.Errorf("token account %s data too short", w.Address)
+	}
+	return binary.LittleEndian.Uint64(data[64:72]), nil
+}
+
+// UIAmount converts a raw balance to human units
+func (w *WatchedAccount) UIAmount(raw uint64) float64 {
+	return float64(raw) / math.Pow10(int(w.Decimals))
+}
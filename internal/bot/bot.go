@@ -2,13 +2,16 @@ package bot
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
 	"time"
 
 	"github.com/magooney-loon/token-2022-refill-bot/internal/config"
 	"github.com/magooney-loon/token-2022-refill-bot/internal/jupiter"
 	"github.com/magooney-loon/token-2022-refill-bot/internal/utils"
 	"github.com/magooney-loon/token-2022-refill-bot/pkg/token2022"
+	"github.com/magooney-loon/token-2022-refill-bot/pkg/wallet"
 
 	"github.com/gagliardetto/solana-go"
 	"github.com/gagliardetto/solana-go/rpc"
@@ -48,28 +51,120 @@ func (b *Bot) Start(ctx context.Context) error {
 
 	utils.Info("Starting bot",
 		"wallet", b.wallet.PublicKey().String(),
-		"token", b.config.Token.InputMint)
+		"token", b.config.Token.InputMint,
+		"watch_mint", b.config.Monitor.WatchMint,
+		"dry_run", b.config.Trading.DryRun)
 
 	// Create RPC client
 	rpcClient := rpc.New(b.config.RPC.Endpoint)
+	b.rpcClient = rpcClient
 
 	// Create token2022 client
 	tokenClient := token2022.NewClient(b.config, rpcClient)
 
 	// Create Jupiter client
-	jupiterClient := jupiter.NewClient(&b.config.Jupiter, tokenClient, b.config.Token.RefreshCache)
+	jupiterClient, err := jupiter.NewClient(&b.config.Jupiter, tokenClient, rpcClient, b.config.Token.RefreshCache)
+	if err != nil {
+		return fmt.Errorf("failed to create Jupiter client: %w", err)
+	}
 
 	// Create trader
-	b.trader = NewTrader(b.config, jupiterClient, rpcClient, b.wallet)
+	b.trader, err = NewTrader(b.config, jupiterClient, rpcClient, b.wallet)
+	if err != nil {
+		return fmt.Errorf("failed to create trader: %w", err)
+	}
+
+	// Create allocator
+	b.allocator = NewAllocator(b.config, rpcClient, tokenClient, b.wallet.PublicKey())
+
+	// Create rebalancer
+	if b.config.Trading.Rebalance.Enabled {
+		b.rebalancer = NewRebalancer(b.config, tokenClient, b.allocator)
+	}
+
+	// Load cost basis, paper trades are kept apart from real ones
+	b.costBasis, err = LoadCostBasisBook(b.wallet.PublicKey().String(), b.config.Trading.DryRun)
+	if err != nil {
+		return fmt.Errorf("failed to load cost basis: %w", err)
+	}
+	b.trader.SetCostBasis(b.costBasis)
+
+	// Load buy conditions state
+	b.buyGuard, err = LoadBuyGuard(b.config, b.wallet.PublicKey().String(), b.config.Trading.DryRun)
+	if err != nil {
+		return fmt.Errorf("failed to load buy guard: %w", err)
+	}
+	b.trader.SetBuyGuard(b.buyGuard)
+
+	// Load split orders, including any left unfinished by a restart
+	if b.config.Trading.TWAP.Enabled {
+		b.twap, err = LoadTWAPScheduler(&b.config.Trading.TWAP, b.wallet.PublicKey().String(), b.config.Trading.DryRun)
+		if err != nil {
+			return fmt.Errorf("failed to load split orders: %w", err)
+		}
+		b.trader.SetTWAP(b.twap)
+	}
+
+	// Open the trade journal, paper trades are journaled apart from real ones
+	journal, err := OpenJournal(b.config.Trading.JournalDir, b.wallet.PublicKey().String(), b.config.Trading.DryRun)
+	if err != nil {
+		return err
+	}
+	defer journal.Close()
+	b.trader.SetJournal(journal)
+
+	// Record this run for uptime statistics
+	runs, err := LoadRunLog(b.config.Trading.JournalDir, b.wallet.PublicKey().String(), b.config.Trading.DryRun)
+	if err != nil {
+		return err
+	}
+	if err := runs.Begin(time.Now()); err != nil {
+		utils.Warn("Failed to save run log", "error", err)
+	}
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	defer func() {
+		if err := runs.Heartbeat(time.Now()); err != nil {
+			utils.Warn("Failed to save run log", "error", err)
+		}
+	}()
+
+	// Create exit manager
+	if b.config.Trading.Exits.Enabled {
+		b.exits = NewExitManager(b.config, b.costBasis, b.allocator)
+	}
 
 	// Create monitor
 	monitor := NewMonitor(
 		rpcClient,
 		b.wallet,
-		b.config.Wallet.MinSolBalance,
+		b.config.Threshold(),
 		time.Duration(b.config.Monitor.CheckIntervalMinutes)*time.Minute,
 	)
 
+	b.monitor = monitor
+	defer b.stopRecheck()
+
+	if b.config.Monitor.WatchMint != "" {
+		monitor.SetMint(b.config.Monitor.WatchMint)
+	}
+	if b.config.Monitor.Websocket {
+		monitor.SetWebsocket(b.config.RPC.WSEndpoint)
+	}
+
+	// Dry-run mode trades against a virtual balance
+	if b.config.Trading.DryRun {
+		ledger, err := LoadPaperLedger(b.wallet.PublicKey().String(), b.config.Token.InputMint, b.config.Trading.PaperBalance)
+		if err != nil {
+			return fmt.Errorf("failed to load paper ledger: %w", err)
+		}
+		b.ledger = ledger
+		b.trader.SetPaperLedger(ledger)
+		b.allocator.SetPaperLedger(ledger)
+		monitor.SetPaperLedger(ledger)
+		utils.Warn("Dry-run mode enabled, no transactions will be sent")
+	}
+
 	// Start monitor in background
 	monitorCtx, cancel := context.WithCancel(ctx)
 	defer cancel()
@@ -80,6 +175,39 @@ func (b *Bot) Start(ctx context.Context) error {
 		}
 	}()
 
+	// Start scheduler in background for DCA buys
+	var scheduleChan <-chan ScheduledTrigger
+	if b.config.Monitor.Mode == "schedule" || b.config.Monitor.Mode == "both" {
+		scheduler, err := NewScheduler(&b.config.Monitor, b.wallet.PublicKey().String(), b.config.Trading.DryRun)
+		if err != nil {
+			return fmt.Errorf("failed to create scheduler: %w", err)
+		}
+		scheduleChan = scheduler.GetTriggerChannel()
+
+		go func() {
+			if err := scheduler.Start(monitorCtx); err != nil && !errors.Is(err, context.Canceled) {
+				b.errorChan <- fmt.Errorf("scheduler error: %w", err)
+			}
+		}()
+	}
+
+	// Start dividend watcher in background to buy with each payout
+	var dividendChan <-chan DividendTrigger
+	if b.config.Monitor.Mode == "dividend" {
+		watcher, err := NewDividendWatcher(rpcClient, b.config.RPC.WSEndpoint, b.wallet.PublicKey(), b.config.Token.DividendMint, b.config.Trading.DryRun)
+		if err != nil {
+			return fmt.Errorf("failed to create dividend watcher: %w", err)
+		}
+		dividendChan = watcher.GetTriggerChannel()
+		b.dividends = watcher
+
+		go func() {
+			if err := watcher.Start(monitorCtx); err != nil && !errors.Is(err, context.Canceled) {
+				b.errorChan <- fmt.Errorf("dividend watcher error: %w", err)
+			}
+		}()
+	}
+
 	// Initialize state
 	b.updateState(State{
 		Status: StatusIdle,
@@ -87,6 +215,15 @@ func (b *Bot) Start(ctx context.Context) error {
 
 	b.isRunning = true
 
+	// Split order slices are checked on their own ticker
+	var twapTick <-chan time.Time
+	if b.twap != nil {
+		ticker := time.NewTicker(twapPollInterval)
+		defer ticker.Stop()
+		twapTick = ticker.C
+		b.runTWAP(ctx)
+	}
+
 	// Main loop
 	for {
 		select {
@@ -105,13 +242,34 @@ func (b *Bot) Start(ctx context.Context) error {
 				utils.Error("Failed to handle balance check", err)
 				b.errorChan <- err
 			}
+			b.retryDividends(ctx)
+
+		case trigger := <-scheduleChan:
+			if err := b.handleScheduledBuy(ctx, trigger); err != nil {
+				utils.Error("Failed to handle scheduled buy", err)
+				b.errorChan <- err
+			}
+
+		case trigger := <-dividendChan:
+			if err := b.handleDividend(ctx, trigger); err != nil {
+				utils.Error("Failed to handle dividend payout", err)
+				b.errorChan <- err
+			}
+
+		case <-twapTick:
+			b.runTWAP(ctx)
+
+		case now := <-heartbeat.C:
+			if err := runs.Heartbeat(now); err != nil {
+				utils.Warn("Failed to save run log", "error", err)
+			}
 
 		case err := <-b.errorChan:
 			utils.Error("Bot error", err)
-			b.updateState(State{
-				Status: StatusError,
-				Errors: b.getState().Errors + 1,
-			})
+			state := b.getState()
+			state.Status = StatusError
+			state.Errors++
+			b.updateState(state)
 		}
 	}
 }
@@ -140,33 +298,703 @@ func (b *Bot) handleBalanceCheck(ctx context.Context, check BalanceCheck) error
 	state.LastSwapTime = check.Timestamp
 	b.updateState(state)
 
-	if check.MetTarget {
-		utils.Info("Balance threshold met, initiating swap",
-			"balance", check.Balance,
-			"threshold", b.config.Wallet.MinSolBalance,
-			"swap_amount", b.config.Token.SwapAmount)
+	var failures []error
+
+	// Held exit proceeds never exceed the input balance, funds withdrawn since stop being reserved
+	if check.Mint == b.config.Token.InputMint {
+		released, err := b.costBasis.Clamp(check.Balance)
+		if err != nil {
+			utils.Warn("Failed to update held exit proceeds", "error", err)
+		} else if released > 0 {
+			utils.Info("Held exit proceeds reduced to balance", "released", released, "balance", check.Balance)
+		}
+	}
+
+	// Exits run first so their proceeds are held back before any refill
+	if b.exits != nil {
+		if err := b.exit(ctx, check, &state); err != nil {
+			failures = append(failures, err)
+		}
+	}
+
+	b.rearm(&state, check)
+	if check.MetTarget && (b.config.Monitor.Mode == "threshold" || b.config.Monitor.Mode == "both") {
+		if reason := b.refillHeld(state, check.Timestamp); reason != "" {
+			utils.Info("Balance threshold met, refill held", "trigger", check.ID, "reason", reason)
+			b.scheduleRecheck(state, check.Timestamp)
+		} else {
+			bought, err := b.refill(ctx, check, &state)
+			b.afterRefill(&state, check.Timestamp, bought, err)
+			if err != nil {
+				b.scheduleRecheck(state, check.Timestamp)
+				failures = append(failures, err)
+			}
+		}
+	}
+
+	if b.rebalancer != nil {
+		if err := b.rebalance(ctx, check, &state); err != nil {
+			failures = append(failures, err)
+		}
+	}
 
-		state.Status = StatusSwapping
+	if len(failures) > 0 {
+		state.Status = StatusError
 		b.updateState(state)
+		return errors.Join(failures...)
+	}
 
-		// Execute swap using trader
-		if err := b.trader.ExecuteSwap(ctx, check.Balance); err != nil {
+	state.Status = StatusIdle
+	b.updateState(state)
+	return nil
+}
+
+// rearm re-enables threshold refills once the balance dropped below the re-arm level
+func (b *Bot) rearm(state *State, check BalanceCheck) {
+	if state.Disarmed && check.Balance < b.config.Monitor.RearmBalance {
+		state.Disarmed = false
+		utils.Debug("Refill re-armed", "balance", check.Balance, "rearm_balance", b.config.Monitor.RearmBalance)
+	}
+}
+
+// refillHeld returns why a threshold refill may not run yet, or "" if it may
+func (b *Bot) refillHeld(state State, now time.Time) string {
+	switch {
+	case now.Before(state.BackoffUntil):
+		return fmt.Sprintf("backing off after %d failures until %s",
+			state.ConsecutiveFailures, state.BackoffUntil.Format(time.RFC3339))
+	case now.Before(state.CooldownUntil):
+		return fmt.Sprintf("cooling down until %s", state.CooldownUntil.Format(time.RFC3339))
+	case state.Disarmed:
+		return fmt.Sprintf("waiting for balance to drop below %.4f", b.config.Monitor.RearmBalance)
+	}
+	return ""
+}
+
+// scheduleRecheck checks the balance again once the cooldown and backoff holding a refill
+// have ended, so a top-up that arrived during them is not left waiting for the next tick
+func (b *Bot) scheduleRecheck(state State, now time.Time) {
+	until := state.CooldownUntil
+	if state.BackoffUntil.After(until) {
+		until = state.BackoffUntil
+	}
+	if !until.After(now) || b.monitor == nil {
+		return
+	}
+
+	b.stopRecheck()
+	b.recheck = time.AfterFunc(until.Sub(now), b.monitor.Recheck)
+	utils.Debug("Refill re-check scheduled", "at", until.Format(time.RFC3339))
+}
+
+// stopRecheck cancels a scheduled re-check
+func (b *Bot) stopRecheck() {
+	if b.recheck != nil {
+		b.recheck.Stop()
+		b.recheck = nil
+	}
+}
+
+// afterRefill starts the cooldown and disarms the threshold after a swap or a scheduled
+// split order, and backs off exponentially after consecutive failures
+func (b *Bot) afterRefill(state *State, now time.Time, bought bool, err error) {
+	if bought {
+		state.CooldownUntil = now.Add(time.Duration(b.config.Monitor.CooldownMinutes) * time.Minute)
+		state.Disarmed = b.config.Monitor.RearmBalance > 0
+	}
+
+	if err == nil {
+		state.ConsecutiveFailures = 0
+		state.BackoffUntil = time.Time{}
+		return
+	}
+
+	state.ConsecutiveFailures++
+	maxBackoff := time.Duration(b.config.Monitor.MaxBackoffMinutes) * time.Minute
+	backoff := maxBackoff
+	if state.ConsecutiveFailures <= 20 {
+		backoff = min(time.Duration(b.config.Monitor.BackoffBaseSeconds)*time.Second<<(state.ConsecutiveFailures-1), maxBackoff)
+	}
+	state.BackoffUntil = now.Add(backoff)
+
+	utils.Warn("Refill failed, backing off",
+		"consecutive_failures", state.ConsecutiveFailures,
+		"retry_after", state.BackoffUntil.Format(time.RFC3339))
+}
+
+// refill swaps the input token above the reserve into the target tokens and reports
+// whether it swapped or scheduled a split order
+func (b *Bot) refill(ctx context.Context, check BalanceCheck, state *State) (bool, error) {
+	amount := b.trader.SwapAmount(check.Balance)
+
+	utils.Info("Balance threshold met, initiating swap",
+		"trigger", check.ID,
+		"balance", check.Balance,
+		"threshold", b.config.Threshold(),
+		"sizing", b.trader.SizingName(),
+		"swap_amount", amount)
+
+	if amount <= 0 {
+		utils.Info("Nothing to swap above reserve", "trigger", check.ID)
+		return false, nil
+	}
+
+	outcome, err := b.buy(ctx, TradeReasonThreshold, check.ID, b.config.RefillMint(), amount, check.Balance, state)
+	return outcome.bought(), err
+}
+
+// handleScheduledBuy buys the scheduled amount regardless of the balance threshold
+func (b *Bot) handleScheduledBuy(ctx context.Context, trigger ScheduledTrigger) error {
+	utils.Info("Scheduled buy triggered",
+		"trigger", trigger.ID,
+		"scheduled_at", trigger.ScheduledAt.UTC().Format(time.RFC3339),
+		"catch_up", trigger.CatchUp,
+		"amount", trigger.Amount)
+
+	balance, err := b.inputBalance(ctx)
+	if err != nil {
+		return fmt.Errorf("scheduled buy failed: %w", err)
+	}
+
+	state := b.getState()
+	state.CurrentBalance = balance
+	if _, err := b.buy(ctx, TradeReasonSchedule, trigger.ID, b.config.Token.InputMint, trigger.Amount, balance, &state); err != nil {
+		state.Status = StatusError
+		b.updateState(state)
+		return err
+	}
+
+	state.Status = StatusIdle
+	b.updateState(state)
+	return nil
+}
+
+// handleDividend buys with a dividend payout as soon as it arrives. The payout first tops
+// the reserve back up, the rest is bought and the pairing recorded in the dividend cache.
+// The payout stays pending with the watcher until that record is written.
+func (b *Bot) handleDividend(ctx context.Context, trigger DividendTrigger) error {
+	owner := b.wallet.PublicKey().String()
+	payout := trigger.Transfer
+
+	// A retry may already have settled a payout still queued on the channel
+	if !b.dividends.IsPending(payout.Signature) {
+		utils.Debug("Dividend payout already settled", "signature", payout.Signature)
+		return nil
+	}
+
+	handled, err := wallet.DividendHandled(owner, payout.Signature)
+	if err != nil {
+		return fmt.Errorf("failed to read dividend cache: %w", err)
+	}
+	if handled {
+		utils.Debug("Dividend payout already handled", "signature", payout.Signature)
+		return b.dividends.Settle(payout.Signature)
+	}
+
+	state := b.getState()
+
+	// Buys made before the record failed are only recorded again
+	if !payout.Handled {
+		payout, err = b.buyDividend(ctx, trigger, &state)
+		var skipped *BuySkippedError
+		if errors.As(err, &skipped) {
+			utils.Info("Dividend buy held back, payout stays pending",
+				"trigger", trigger.ID,
+				"reason", skipped.Reason)
+			state.Status = StatusIdle
+			b.updateState(state)
+			return nil
+		}
+		if err != nil {
 			state.Status = StatusError
-			state.Errors++
 			b.updateState(state)
-			return fmt.Errorf("swap failed: %w", err)
+			return err
 		}
+	}
 
-		state.Status = StatusIdle
-		state.TotalSwaps++
-		state.LastSwapAmount = b.config.Token.SwapAmount
-		state.LastSwapTime = time.Now()
-		b.updateState(state)
+	// Paper buys are kept out of the real dividend history
+	if b.ledger == nil {
+		if err := wallet.RecordDividendBuy(owner, payout, payout.BuySignatures, payout.BoughtAmount); err != nil {
+			if err := b.dividends.Bought(payout); err != nil {
+				utils.Warn("Failed to save dividend buys", "signature", payout.Signature, "error", err)
+			}
+			state.Status = StatusError
+			b.updateState(state)
+			return fmt.Errorf("failed to record dividend buy %s: %w", payout.Signature, err)
+		}
+	}
+	if err := b.dividends.Settle(payout.Signature); err != nil {
+		utils.Warn("Failed to save dividend watcher state", "signature", payout.Signature, "error", err)
 	}
 
+	state.Status = StatusIdle
+	b.updateState(state)
 	return nil
 }
 
+// buyDividend swaps what a payout leaves after topping up the reserve and returns the payout
+// with its buys and split orders. A payout nothing was bought or scheduled with returns an
+// error, a BuySkippedError when the buy conditions held every allocation back, so it is retried.
+func (b *Bot) buyDividend(ctx context.Context, trigger DividendTrigger, state *State) (wallet.DividendTransfer, error) {
+	payout := trigger.Transfer
+
+	balance, err := b.inputBalance(ctx)
+	if err != nil {
+		return payout, fmt.Errorf("dividend buy failed: %w", err)
+	}
+
+	// Whatever the balance lacked before the payout to cover the reserve is kept back
+	topUp := math.Max(0, b.trader.Reserve()-(balance-payout.Amount))
+	amount := payout.Amount - topUp
+
+	utils.Info("Dividend buy triggered",
+		"trigger", trigger.ID,
+		"payout", payout.Amount,
+		"balance", balance,
+		"reserve_top_up", math.Min(topUp, payout.Amount),
+		"swap_amount", math.Max(amount, 0))
+
+	state.CurrentBalance = balance
+
+	var outcome buyOutcome
+	if amount > 0 {
+		outcome, err = b.buy(ctx, TradeReasonDividend, trigger.ID, b.config.Token.InputMint, amount, balance, state)
+		if err == nil && !outcome.bought() {
+			return payout, &BuySkippedError{Reason: "buy conditions held back every allocation"}
+		}
+	} else {
+		utils.Info("Dividend payout used to top up the reserve, nothing to buy", "trigger", trigger.ID)
+	}
+	if err != nil && !outcome.bought() {
+		return payout, err
+	}
+
+	// Buys that completed are kept with the payout, the rest of a partial failure is not retried
+	if err != nil {
+		utils.Warn("Dividend buy partly failed", "trigger", trigger.ID, "error", err)
+	}
+
+	payout.BuySignatures = make([]string, 0, len(outcome.swaps))
+	for _, result := range outcome.swaps {
+		payout.BuySignatures = append(payout.BuySignatures, result.TxSignature)
+		payout.BoughtAmount += result.InputAmount
+	}
+	payout.BuyOrders = nil
+	for _, order := range outcome.orders {
+		payout.BuyOrders = append(payout.BuyOrders, order.ID)
+		payout.BoughtAmount += order.Amount
+	}
+	utils.Info("Dividend payout paired with buys",
+		"payout", payout.Signature,
+		"buys", payout.BuySignatures,
+		"split_orders", payout.BuyOrders,
+		"bought", payout.BoughtAmount)
+
+	return payout, nil
+}
+
+// retryDividends handles again the payouts whose buy or record failed earlier
+func (b *Bot) retryDividends(ctx context.Context) {
+	if b.dividends == nil {
+		return
+	}
+
+	for _, payout := range b.dividends.PendingPayouts() {
+		trigger := DividendTrigger{ID: "dividend-" + payout.Signature, Transfer: payout}
+		if err := b.handleDividend(ctx, trigger); err != nil {
+			utils.Error("Failed to retry dividend payout", err)
+		}
+	}
+}
+
+// buyOutcome is what a buy did: the swaps that completed and the split orders it scheduled
+type buyOutcome struct {
+	swaps  []*SwapResult
+	orders []*TWAPOrder
+}
+
+// bought reports whether the buy swapped or scheduled anything
+func (o buyOutcome) bought() bool {
+	return len(o.swaps) > 0 || len(o.orders) > 0
+}
+
+// buy splits amount of inputMint among the targets and swaps into each of them,
+// returning the swaps that completed and the split orders whose slices execute later
+func (b *Bot) buy(ctx context.Context, reason, triggerID, inputMint string, amount, balance float64, state *State) (buyOutcome, error) {
+	state.Status = StatusSwapping
+	b.updateState(*state)
+
+	var outcome buyOutcome
+
+	// Split the refill among the target tokens
+	allocations, err := b.allocator.Allocate(ctx, inputMint, amount)
+	if err != nil {
+		state.Errors++
+		return outcome, fmt.Errorf("allocation failed: %w", err)
+	}
+
+	remaining := balance
+	var failures []error
+	var swapped float64
+	for _, allocation := range allocations {
+		// Large buys are split into slices executed on the split order ticker
+		if b.twap != nil && b.twap.ShouldSplit(allocation.Amount) {
+			id := fmt.Sprintf("%s:%s", triggerID, allocation.Mint)
+
+			// Input owed to open orders is spoken for, so the same funds are not scheduled twice
+			if available := b.trader.Available(inputMint, remaining); !b.twap.Scheduled(id) && allocation.Amount > available {
+				state.Errors++
+				failures = append(failures, fmt.Errorf("insufficient balance for split order into %s: have %.6f after reserve and open orders, need %.6f",
+					allocation.Mint, math.Max(available, 0), allocation.Amount))
+				continue
+			}
+
+			order, err := b.twap.Schedule(id, inputMint, allocation.Mint, allocation.Amount)
+			if err != nil {
+				state.Errors++
+				failures = append(failures, fmt.Errorf("failed to schedule split order into %s: %w", allocation.Mint, err))
+				continue
+			}
+			outcome.orders = append(outcome.orders, order)
+			continue
+		}
+
+		// Execute swap using trader
+		result, err := b.trader.ExecuteSwap(ctx, SwapOrder{
+			TriggerID:  fmt.Sprintf("%s:%s", triggerID, allocation.Mint),
+			Reason:     reason,
+			InputMint:  inputMint,
+			OutputMint: allocation.Mint,
+			Amount:     allocation.Amount,
+			Balance:    remaining,
+		})
+		var skipped *BuySkippedError
+		if errors.As(err, &skipped) {
+			utils.Info("Buy condition not met, retrying next tick",
+				"trigger", triggerID,
+				"mint", allocation.Mint,
+				"reason", skipped.Reason)
+			continue
+		}
+		if err != nil {
+			state.Errors++
+			failures = append(failures, fmt.Errorf("swap into %s failed: %w", allocation.Mint, err))
+			continue
+		}
+
+		if err := b.buyGuard.RecordBuy(result); err != nil {
+			utils.Warn("Failed to record last buy", "signature", result.TxSignature, "error", err)
+		}
+
+		remaining -= result.InputAmount + result.Fee
+		swapped += result.InputAmount
+		outcome.swaps = append(outcome.swaps, result)
+		b.recordSwap(ctx, state, result)
+	}
+
+	if swapped > 0 {
+		state.LastSwapAmount = swapped
+	}
+
+	return outcome, errors.Join(failures...)
+}
+
+// runTWAP executes the split order slices that are due. Slices sent before a restart
+// are looked up first, so they are only sent again if they can no longer land.
+func (b *Bot) runTWAP(ctx context.Context) {
+	due := b.twap.Due(time.Now())
+	if len(due) == 0 {
+		return
+	}
+
+	state := b.getState()
+	state.Status = StatusSwapping
+	b.updateState(state)
+
+	for _, item := range due {
+		order, child := item.Order, item.Child
+		triggerID := order.ChildTriggerID(child.Index)
+
+		if child.Status == ChildSubmitted {
+			result, err := b.recoverChild(ctx, order, child)
+			if err != nil {
+				utils.Warn("Split order slice outcome unknown, checking again later",
+					"order", order.ID,
+					"slice", child.Index,
+					"error", err)
+				continue
+			}
+			if result != nil {
+				b.completeChild(ctx, &state, order, child.Index, result)
+				continue
+			}
+			b.twap.Release(order.ID, child.Index)
+		}
+
+		balance, err := b.balance(ctx, order.InputMint)
+		if err != nil {
+			utils.Warn("Failed to get balance for split order slice", "order", order.ID, "error", err)
+			continue
+		}
+
+		utils.Info("Executing split order slice",
+			"order", order.ID,
+			"slice", fmt.Sprintf("%d/%d", child.Index+1, len(order.Children)),
+			"amount", child.Amount)
+
+		result, err := b.trader.ExecuteSwap(ctx, SwapOrder{
+			TriggerID:  triggerID,
+			Reason:     TradeReasonTWAP,
+			InputMint:  order.InputMint,
+			OutputMint: order.OutputMint,
+			Amount:     child.Amount,
+			Balance:    balance,
+			OnSubmit: func(sub Submission) {
+				b.twap.MarkSubmitted(order.ID, child.Index, sub)
+			},
+		})
+		if err != nil {
+			var skipped *BuySkippedError
+			if !errors.As(err, &skipped) {
+				state.Errors++
+			}
+			utils.Warn("Split order slice did not complete, retrying later",
+				"order", order.ID,
+				"slice", child.Index,
+				"error", err)
+			b.twap.Retry(order.ID, child.Index, err)
+			b.finishTWAP(order.ID)
+			continue
+		}
+
+		b.completeChild(ctx, &state, order, child.Index, result)
+	}
+
+	state.Status = StatusIdle
+	b.updateState(state)
+}
+
+// recoverChild looks up the transactions recorded for a slice and returns its swap if one landed
+func (b *Bot) recoverChild(ctx context.Context, order *TWAPOrder, child TWAPChild) (*SwapResult, error) {
+	for _, sub := range child.Submissions {
+		result, err := b.trader.RecoverSubmission(ctx, sub, order.InputMint, order.OutputMint)
+		if err != nil {
+			return nil, err
+		}
+		if result != nil {
+			utils.Info("Recovered split order slice",
+				"order", order.ID,
+				"slice", child.Index,
+				"signature", result.TxSignature)
+			return result, nil
+		}
+	}
+	return nil, nil
+}
+
+// completeChild records a slice's swap and closes the order once every slice finished
+func (b *Bot) completeChild(ctx context.Context, state *State, order *TWAPOrder, index int, result *SwapResult) {
+	b.twap.Complete(order.ID, index, result)
+	b.recordSwap(ctx, state, result)
+	if err := b.buyGuard.RecordBuy(result); err != nil {
+		utils.Warn("Failed to record last buy", "signature", result.TxSignature, "error", err)
+	}
+	b.finishTWAP(order.ID)
+}
+
+// finishTWAP logs and removes a split order once every slice is done or failed
+func (b *Bot) finishTWAP(orderID string) {
+	order := b.twap.Finish(orderID)
+	if order == nil {
+		return
+	}
+
+	var input, output float64
+	failed := 0
+	for _, child := range order.Children {
+		input += child.InputAmount
+		output += child.OutputAmount
+		if child.Status == ChildFailed {
+			failed++
+		}
+	}
+
+	utils.Info("Split order finished",
+		"order", order.ID,
+		"output_mint", order.OutputMint,
+		"amount", order.Amount,
+		"input_amount", input,
+		"output_amount", output,
+		"failed_slices", failed)
+}
+
+// inputBalance returns the current input token balance
+func (b *Bot) inputBalance(ctx context.Context) (float64, error) {
+	return b.balance(ctx, b.config.Token.InputMint)
+}
+
+// balance returns the wallet's current balance of mint. In dry-run mode it is the virtual
+// balance, with real balance changes mirrored in first.
+func (b *Bot) balance(ctx context.Context, mint string) (float64, error) {
+	account, ok := b.mintAccounts[mint]
+	if !ok {
+		var err error
+		account, err = ResolveWatchedAccount(ctx, b.rpcClient, b.wallet.PublicKey(), mint)
+		if err != nil {
+			return 0, err
+		}
+		if b.mintAccounts == nil {
+			b.mintAccounts = make(map[string]*WatchedAccount)
+		}
+		b.mintAccounts[mint] = account
+	}
+
+	amount, err := account.Fetch(ctx, b.rpcClient, rpc.CommitmentConfirmed)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get balance: %w", err)
+	}
+	balance := account.UIAmount(amount)
+
+	if b.ledger != nil {
+		balance, err = b.ledger.Sync(mint, balance)
+		if err != nil {
+			utils.Warn("Failed to save paper ledger", "error", err)
+		}
+	}
+	return balance, nil
+}
+
+// rebalance sells targets that drifted above their weight
+func (b *Bot) rebalance(ctx context.Context, check BalanceCheck, state *State) error {
+	trades, err := b.rebalancer.Plan(ctx)
+	if err != nil {
+		state.Errors++
+		return fmt.Errorf("rebalance planning failed: %w", err)
+	}
+	if len(trades) == 0 {
+		return nil
+	}
+
+	state.Status = StatusSwapping
+	b.updateState(*state)
+
+	var failures []error
+	for _, trade := range trades {
+		utils.Info("Target above drift band, rebalancing",
+			"trigger", check.ID,
+			"mint", trade.InputMint,
+			"into", trade.OutputMint,
+			"drift", fmt.Sprintf("%.2f%%", trade.Drift),
+			"amount", trade.Amount,
+			"value", fmt.Sprintf("$%.2f", trade.Value),
+			"fee_cost", fmt.Sprintf("$%.2f", trade.FeeCost))
+
+		result, err := b.trader.ExecuteSwap(ctx, SwapOrder{
+			TriggerID:  fmt.Sprintf("%s:rebalance:%s", check.ID, trade.InputMint),
+			Reason:     TradeReasonRebalance,
+			InputMint:  trade.InputMint,
+			OutputMint: trade.OutputMint,
+			Amount:     trade.Amount,
+			Balance:    trade.Balance,
+		})
+		if err != nil {
+			state.Errors++
+			failures = append(failures, fmt.Errorf("rebalance sell of %s failed: %w", trade.InputMint, err))
+			continue
+		}
+
+		b.recordSwap(ctx, state, result)
+	}
+
+	return errors.Join(failures...)
+}
+
+// exit sells held targets whose take-profit or stop-loss rule triggered
+func (b *Bot) exit(ctx context.Context, check BalanceCheck, state *State) error {
+	trades, err := b.exits.Plan(ctx)
+	if err != nil {
+		state.Errors++
+		return fmt.Errorf("exit evaluation failed: %w", err)
+	}
+	if len(trades) == 0 {
+		return nil
+	}
+
+	state.Status = StatusSwapping
+	b.updateState(*state)
+
+	var failures []error
+	for _, trade := range trades {
+		utils.Info("Exit rule triggered",
+			"trigger", check.ID,
+			"mint", trade.Mint,
+			"reason", trade.Reason,
+			"price", trade.Price,
+			"average_cost", trade.AverageCost,
+			"change", fmt.Sprintf("%.2f%%", trade.Change*100),
+			"amount", trade.Amount)
+
+		result, err := b.trader.ExecuteSwap(ctx, SwapOrder{
+			TriggerID:  fmt.Sprintf("%s:%s:%s", check.ID, trade.Reason, trade.Mint),
+			Reason:     trade.Reason,
+			InputMint:  trade.Mint,
+			OutputMint: b.config.Token.InputMint,
+			Amount:     trade.Amount,
+			Balance:    trade.Balance,
+		})
+		if err != nil {
+			state.Errors++
+			failures = append(failures, fmt.Errorf("%s sell of %s failed: %w", trade.Reason, trade.Mint, err))
+			continue
+		}
+
+		b.recordSwap(ctx, state, result)
+
+		if trade.Reason == ExitTakeProfit {
+			if err := b.costBasis.MarkTakeProfit(trade.Mint, trade.Price); err != nil {
+				utils.Warn("Failed to record take-profit level", "mint", trade.Mint, "error", err)
+			}
+		}
+
+		if !b.config.Trading.Exits.RebuyProceeds {
+			// Proceeds can't be held beyond what the wallet actually has
+			balance, err := b.inputBalance(ctx)
+			if err != nil {
+				utils.Warn("Failed to get balance, exit proceeds not held back", "amount", result.OutputAmount, "error", err)
+				continue
+			}
+			if err := b.costBasis.Lock(result.OutputAmount, balance); err != nil {
+				utils.Warn("Failed to hold back exit proceeds", "amount", result.OutputAmount, "error", err)
+			}
+			utils.Info("Exit proceeds held in reserve",
+				"amount", result.OutputAmount,
+				"locked_total", b.costBasis.Locked())
+		}
+	}
+
+	return errors.Join(failures...)
+}
+
+// recordSwap adds a completed swap to the running totals and the cost basis
+func (b *Bot) recordSwap(ctx context.Context, state *State, result *SwapResult) {
+	prices, err := wallet.GetTokenPrices(ctx, b.config, []string{result.InputMint, result.OutputMint, NativeMint})
+	if err != nil {
+		utils.Warn("Failed to price swap, cost basis not updated", "signature", result.TxSignature, "error", err)
+	} else if err := b.costBasis.Record(result, prices, b.config.Token.IsTarget); err != nil {
+		utils.Warn("Failed to update cost basis", "signature", result.TxSignature, "error", err)
+	}
+
+	state.TotalSwaps++
+	state.TotalFees += result.Fee
+	state.LastSwapTime = result.Timestamp
+
+	utils.Info("Swap fees",
+		"fee", fmt.Sprintf("%.9f SOL", result.Fee),
+		"priority_fee", fmt.Sprintf("%.9f SOL", result.PriorityFee),
+		"total_fees", fmt.Sprintf("%.9f SOL", state.TotalFees),
+		"total_swaps", state.TotalSwaps)
+}
+
 // getState safely retrieves the current state
 func (b *Bot) getState() State {
 	select {
@@ -189,14 +1017,11 @@ func (b *Bot) updateState(state State) {
 	b.stateChan <- state
 }
 
-// GetStats returns the current bot statistics
+// GetStats returns the bot statistics computed from the trade journal and run log
 func (b *Bot) GetStats() BotStats {
-	state := b.getState()
-	return BotStats{
-		StartTime:       time.Now(), // TODO: Track actual start time
-		TotalSwaps:      state.TotalSwaps,
-		SuccessfulSwaps: state.TotalSwaps - state.Errors,
-		FailedSwaps:     state.Errors,
-		// Other stats to be implemented
+	stats, err := LoadStats(b.config, b.wallet.PublicKey().String(), b.config.Trading.DryRun)
+	if err != nil {
+		utils.Warn("Failed to load statistics", "error", err)
 	}
+	return stats
 }
@@ -0,0 +1,221 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/magooney-loon/token-2022-refill-bot/internal/jupiter"
+	"github.com/magooney-loon/token-2022-refill-bot/internal/utils"
+	"github.com/magooney-loon/token-2022-refill-bot/pkg/token2022"
+
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// Broadcaster sends a signed transaction and keeps re-sending it until it lands or expires
+type Broadcaster struct {
+	rpcClient      *rpc.Client
+	confirmer      *Confirmer
+	resendInterval time.Duration
+}
+
+// NewBroadcaster creates a new transaction broadcaster
+func NewBroadcaster(rpcClient *rpc.Client, confirmer *Confirmer, resendInterval time.Duration) *Broadcaster {
+	return &Broadcaster{
+		rpcClient:      rpcClient,
+		confirmer:      confirmer,
+		resendInterval: resendInterval,
+	}
+}
+
+// SendAndConfirm sends the transaction with preflight checks, then re-sends the same
+// signed bytes on every interval until it is confirmed, fails, or its blockhash expires
+func (b *Broadcaster) SendAndConfirm(ctx context.Context, swapTx *jupiter.SwapTransaction) (*rpc.SignatureStatusesResult, error) {
+	// The first send runs preflight so obviously broken transactions fail fast
+	if _, err := b.rpcClient.SendTransactionWithOpts(ctx, swapTx.Transaction, rpc.TransactionOpts{
+		PreflightCommitment: rpc.CommitmentConfirmed,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to send transaction: %w", err)
+	}
+
+	utils.Info("Transaction sent",
+		"signature", fmt.Sprintf("https://solscan.io/tx/%s", swapTx.Signature),
+		"last_valid_block_height", swapTx.LastValidBlockHeight)
+
+	resendCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	go b.rebroadcast(resendCtx, swapTx)
+
+	return b.confirmer.WaitForConfirmation(ctx, swapTx.Signature, swapTx.LastValidBlockHeight)
+}
+
+// rebroadcast re-sends the transaction until ctx is cancelled or the blockhash expires
+func (b *Broadcaster) rebroadcast(ctx context.Context, swapTx *jupiter.SwapTransaction) {
+	ticker := time.NewTicker(b.resendInterval)
+	defer ticker.Stop()
+
+	maxRetries := uint(0)
+	sends := 1
+
+	for {
+		select {
+		case <-ctx.Done():
+			utils.Debug("Rebroadcast stopped", "signature", swapTx.Signature.String(), "sends", sends)
+			return
+		case <-ticker.C:
+		}
+
+		if swapTx.LastValidBlockHeight > 0 {
+			if expired, err := b.confirmer.IsExpired(ctx, swapTx.LastValidBlockHeight); err == nil && expired {
+				utils.Debug("Blockhash expired, rebroadcast stopped", "signature", swapTx.Signature.String(), "sends", sends)
+				return
+			}
+		}
+
+		// Same signed bytes, so re-sending can never produce a second swap
+		_, err := b.rpcClient.SendTransactionWithOpts(ctx, swapTx.Transaction, rpc.TransactionOpts{
+			SkipPreflight: true,
+			MaxRetries:    &maxRetries,
+		})
+		if err != nil {
+			utils.Debug("Rebroadcast failed", "signature", swapTx.Signature.String(), "error", err)
+			continue
+		}
+		sends++
+	}
+}
+
+// swapAttempt is a single signed transaction sent for a trigger
+type swapAttempt struct {
+	reason         string
+	swapTx         *jupiter.SwapTransaction
+	quote          *jupiter.Quote
+	inputToken     *token2022.TokenInfo
+	outputToken    *token2022.TokenInfo
+	expectedOutput float64 // Quoted output net of the output token's transfer fee
+	priceImpact    float64
+	settled        bool
+}
+
+// triggerSwaps holds everything sent on behalf of one trigger
+type triggerSwaps struct {
+	createdAt time.Time
+	attempts  []*swapAttempt
+	result    *SwapResult
+}
+
+// swapGuard tracks the transactions sent for each trigger so one trigger can never
+// produce two confirmed swaps
+type swapGuard struct {
+	mu       sync.Mutex
+	triggers map[string]*triggerSwaps
+}
+
+func newSwapGuard() *swapGuard {
+	return &swapGuard{
+		triggers: make(map[string]*triggerSwaps),
+	}
+}
+
+// Result returns the confirmed swap for a trigger, if any
+func (g *swapGuard) Result(triggerID string) *SwapResult {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if entry, ok := g.triggers[triggerID]; ok {
+		return entry.result
+	}
+	return nil
+}
+
+// AddAttempt records a transaction about to be sent for a trigger
+func (g *swapGuard) AddAttempt(triggerID string, attempt *swapAttempt) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	entry, ok := g.triggers[triggerID]
+	if !ok {
+		entry = &triggerSwaps{createdAt: time.Now()}
+		g.triggers[triggerID] = entry
+	}
+	entry.attempts = append(entry.attempts, attempt)
+}
+
+// Complete records the confirmed swap for a trigger and settles its other attempts
+func (g *swapGuard) Complete(triggerID string, result *SwapResult) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if entry, ok := g.triggers[triggerID]; ok {
+		entry.result = result
+		for _, attempt := range entry.attempts {
+			attempt.settled = true
+		}
+	}
+}
+
+// Unsettled returns the attempts for a trigger whose outcome is still unknown
+func (g *swapGuard) Unsettled(triggerID string) []*swapAttempt {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var pending []*swapAttempt
+	if entry, ok := g.triggers[triggerID]; ok {
+		for _, attempt := range entry.attempts {
+			if !attempt.settled {
+				pending = append(pending, attempt)
+			}
+		}
+	}
+	return pending
+}
+
+// PendingTriggers returns all triggers other than exclude with unsettled attempts
+func (g *swapGuard) PendingTriggers(exclude string) []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var ids []string
+	for id, entry := range g.triggers {
+		if id == exclude || entry.result != nil {
+			continue
+		}
+		for _, attempt := range entry.attempts {
+			if !attempt.settled {
+				ids = append(ids, id)
+				break
+			}
+		}
+	}
+	return ids
+}
+
+// Settle marks an attempt as resolved without a swap
+func (g *swapGuard) Settle(attempt *swapAttempt) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	attempt.settled = true
+}
+
+// Prune drops fully settled triggers older than maxAge
+func (g *swapGuard) Prune(maxAge time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for id, entry := range g.triggers {
+		if time.Since(entry.createdAt) < maxAge {
+			continue
+		}
+		settled := true
+		for _, attempt := range entry.attempts {
+			if !attempt.settled {
+				settled = false
+				break
+			}
+		}
+		if settled {
+			delete(g.triggers, id)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/magooney-loon/token-2022-refill-bot/internal/config"
+	"github.com/magooney-loon/token-2022-refill-bot/internal/utils"
+	"github.com/magooney-loon/token-2022-refill-bot/pkg/wallet"
+)
+
+// minAverageSamples is how many quotes must be observed before the moving average guard applies
+const minAverageSamples = 3
+
+// BuySkippedError is returned when a buy condition holds a swap back until a later tick
+type BuySkippedError struct {
+	Reason string
+}
+
+func (e *BuySkippedError) Error() string {
+	return fmt.Sprintf("buy skipped: %s", e.Reason)
+}
+
+// BuyGuard checks quoted buy prices against the configured buy conditions. It keeps the
+// recent quote prices and the rate of the last buy of each target on disk.
+type BuyGuard struct {
+	mu     sync.Mutex
+	path   string
+	config *config.Config
+
+	Samples   map[string][]float64 `json:"samples"`   // Recent quoted input per output token
+	LastBuys  map[string]float64   `json:"last_buys"` // Output tokens per input token of the last buy
+	UpdatedAt time.Time            `json:"updated_at"`
+}
+
+// LoadBuyGuard loads the wallet's buy guard state, paper trades are kept in their own file
+func LoadBuyGuard(cfg *config.Config, wallet string, paper bool) (*BuyGuard, error) {
+	cacheDir := "cache"
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return nil, err
+	}
+
+	name := fmt.Sprintf("buy_guard_%s.json", wallet[:8])
+	if paper {
+		name = fmt.Sprintf("buy_guard_paper_%s.json", wallet[:8])
+	}
+
+	guard := &BuyGuard{
+		path:     filepath.Join(cacheDir, name),
+		config:   cfg,
+		Samples:  make(map[string][]float64),
+		LastBuys: make(map[string]float64),
+	}
+
+	data, err := os.ReadFile(guard.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return guard, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, guard); err != nil {
+		return nil, fmt.Errorf("failed to parse buy guard state: %w", err)
+	}
+	if guard.Samples == nil {
+		guard.Samples = make(map[string][]float64)
+	}
+	if guard.LastBuys == nil {
+		guard.LastBuys = make(map[string]float64)
+	}
+
+	return guard, nil
+}
+
+// Check evaluates the buy conditions for a quote of inAmount input tokens for outAmount
+// output tokens. It records the quote price and returns a BuySkippedError if a condition fails.
+func (g *BuyGuard) Check(ctx context.Context, inputMint, outputMint string, inAmount, outAmount float64) error {
+	if inAmount <= 0 || outAmount <= 0 {
+		return nil
+	}
+	rules := g.config.Trading.BuyGuard
+	price := inAmount / outAmount
+
+	// Maximum USD price paid per output token
+	maxPrice := rules.MaxPriceUSD
+	for _, target := range g.config.Token.Targets {
+		if target.Mint == outputMint && target.MaxPriceUSD > 0 {
+			maxPrice = target.MaxPriceUSD
+		}
+	}
+	if maxPrice > 0 {
+		prices, err := wallet.GetTokenPrices(ctx, g.config, []string{inputMint})
+		if err != nil {
+			return &BuySkippedError{Reason: fmt.Sprintf("failed to get input price: %v", err)}
+		}
+		if usdPrice := price * prices[inputMint]; usdPrice > maxPrice {
+			return &BuySkippedError{Reason: fmt.Sprintf("price $%.8f above max $%.8f", usdPrice, maxPrice)}
+		}
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	// Deviation from the moving average of earlier quotes
+	samples := g.Samples[outputMint]
+	var reason string
+	if rules.MaxDeviationPercent > 0 && len(samples) >= minAverageSamples {
+		sum := 0.0
+		for _, sample := range samples {
+			sum += sample
+		}
+		average := sum / float64(len(samples))
+		if deviation := price/average - 1; deviation > rules.MaxDeviationPercent/100 {
+			reason = fmt.Sprintf("price %.2f%% above %d-quote average (max %.2f%%)",
+				deviation*100, len(samples), rules.MaxDeviationPercent)
+		}
+	}
+
+	// Output per input must beat the last buy
+	if reason == "" && rules.BetterThanLastBuy {
+		if last := g.LastBuys[outputMint]; last > 0 && outAmount/inAmount <= last {
+			reason = fmt.Sprintf("%.6f per input token is not better than last buy at %.6f", outAmount/inAmount, last)
+		}
+	}
+
+	samples = append(samples, price)
+	if len(samples) > rules.AverageWindow {
+		samples = samples[len(samples)-rules.AverageWindow:]
+	}
+	g.Samples[outputMint] = samples
+
+	if err := g.save(); err != nil {
+		utils.Warn("Failed to save buy guard state", "error", err)
+	}
+
+	if reason != "" {
+		return &BuySkippedError{Reason: reason}
+	}
+	return nil
+}
+
+// RecordBuy stores the rate of a completed buy for the better-than-last-buy condition
+func (g *BuyGuard) RecordBuy(result *SwapResult) error {
+	if result.InputAmount <= 0 || result.OutputAmount <= 0 {
+		return nil
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.LastBuys[result.OutputMint] = result.OutputAmount / result.InputAmount
+	return g.save()
+}
+
+// save writes the guard state to disk, the caller must hold the lock
+func (g *BuyGuard) save() error {
+	g.UpdatedAt = time.Now()
+
+	data, err := json.MarshalIndent(g, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write to a temp file first so a crash can't corrupt the state
+	tmp := g.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, g.path)
+}
@@ -0,0 +1,226 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/magooney-loon/token-2022-refill-bot/internal/utils"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+const (
+	// NativeMint is the wrapped SOL mint used by Jupiter for SOL legs
+	NativeMint = "So11111111111111111111111111111111111111112"
+
+	confirmPollInterval = 2 * time.Second
+
+	// baseFeeLamports is the network fee charged per transaction signature
+	baseFeeLamports = 5000
+)
+
+// ErrBlockhashExpired is returned when a transaction's blockhash expired before it landed
+var ErrBlockhashExpired = errors.New("transaction blockhash expired before confirmation")
+
+// TransactionFailedError is returned when a transaction landed but failed on-chain
+type TransactionFailedError struct {
+	Signature solana.Signature
+	Err       interface{}
+}
+
+func (e *TransactionFailedError) Error() string {
+	return fmt.Sprintf("transaction %s failed on-chain: %v", e.Signature, e.Err)
+}
+
+// Confirmer waits for transactions to reach a commitment level and reads their outcome
+type Confirmer struct {
+	rpcClient  *rpc.Client
+	commitment rpc.CommitmentType
+	timeout    time.Duration
+}
+
+// NewConfirmer creates a new transaction confirmer
+func NewConfirmer(rpcClient *rpc.Client, commitment rpc.CommitmentType, timeout time.Duration) *Confirmer {
+	return &Confirmer{
+		rpcClient:  rpcClient,
+		commitment: commitment,
+		timeout:    timeout,
+	}
+}
+
+// WaitForConfirmation polls the signature status until the transaction reaches the
+// configured commitment, fails on-chain, or its blockhash expires
+func (c *Confirmer) WaitForConfirmation(ctx context.Context, sig solana.Signature, lastValidBlockHeight uint64) (*rpc.SignatureStatusesResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(confirmPollInterval)
+	defer ticker.Stop()
+
+	utils.Debug("Waiting for transaction confirmation",
+		"signature", sig.String(),
+		"commitment", c.commitment,
+		"last_valid_block_height", lastValidBlockHeight)
+
+	for {
+		status, err := c.GetStatus(ctx, sig, false)
+		if err != nil {
+			utils.Debug("Failed to get signature status", "signature", sig.String(), "error", err)
+		} else if status != nil {
+			if status.Err != nil {
+				return status, &TransactionFailedError{Signature: sig, Err: status.Err}
+			}
+			if commitmentReached(status.ConfirmationStatus, c.commitment) {
+				utils.Debug("Transaction confirmed",
+					"signature", sig.String(),
+					"slot", status.Slot,
+					"status", status.ConfirmationStatus)
+				return status, nil
+			}
+		}
+
+		if lastValidBlockHeight > 0 {
+			expired, err := c.IsExpired(ctx, lastValidBlockHeight)
+			if err != nil {
+				utils.Debug("Failed to get block height", "error", err)
+			} else if expired {
+				// The transaction may have landed between our last poll and expiry. Once the
+				// blockhash expired a processed transaction can only still be dropped with its
+				// fork, so it counts at confirmed or above and is polled until it gets there.
+				target := c.commitment
+				if target == rpc.CommitmentProcessed {
+					target = rpc.CommitmentConfirmed
+				}
+
+				status, err := c.GetStatus(ctx, sig, true)
+				if err != nil || status == nil {
+					return nil, ErrBlockhashExpired
+				}
+				if status.Err != nil {
+					return status, &TransactionFailedError{Signature: sig, Err: status.Err}
+				}
+				if commitmentReached(status.ConfirmationStatus, target) {
+					return status, nil
+				}
+				utils.Debug("Transaction seen after blockhash expiry, waiting for confirmation",
+					"signature", sig.String(),
+					"status", status.ConfirmationStatus)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("timed out waiting for confirmation of %s: %w", sig, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// GetStatus returns the current status of a signature, or nil if the network doesn't know it
+func (c *Confirmer) GetStatus(ctx context.Context, sig solana.Signature, searchHistory bool) (*rpc.SignatureStatusesResult, error) {
+	statuses, err := c.rpcClient.GetSignatureStatuses(ctx, searchHistory, sig)
+	if err != nil {
+		return nil, err
+	}
+	if len(statuses.Value) == 0 {
+		return nil, nil
+	}
+	return statuses.Value[0], nil
+}
+
+// IsExpired reports whether the current block height has passed lastValidBlockHeight
+func (c *Confirmer) IsExpired(ctx context.Context, lastValidBlockHeight uint64) (bool, error) {
+	height, err := c.rpcClient.GetBlockHeight(ctx, rpc.CommitmentConfirmed)
+	if err != nil {
+		return false, err
+	}
+	return height > lastValidBlockHeight, nil
+}
+
+// FetchTransaction loads a confirmed transaction together with its meta
+func (c *Confirmer) FetchTransaction(ctx context.Context, sig solana.Signature) (*rpc.GetTransactionResult, error) {
+	// getTransaction doesn't accept processed commitment
+	commitment := c.commitment
+	if commitment == rpc.CommitmentProcessed {
+		commitment = rpc.CommitmentConfirmed
+	}
+
+	maxVersion := uint64(0)
+	return utils.WithRetry(func() (*rpc.GetTransactionResult, error) {
+		tx, err := c.rpcClient.GetTransaction(ctx, sig, &rpc.GetTransactionOpts{
+			Commitment:                     commitment,
+			MaxSupportedTransactionVersion: &maxVersion,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if tx.Meta == nil {
+			return nil, fmt.Errorf("transaction %s has no meta", sig)
+		}
+		return tx, nil
+	}, 5, confirmPollInterval)
+}
+
+// commitmentReached reports whether status is at or beyond the target commitment
+func commitmentReached(status rpc.ConfirmationStatusType, target rpc.CommitmentType) bool {
+	rank := map[string]int{
+		string(rpc.ConfirmationStatusProcessed): 1,
+		string(rpc.ConfirmationStatusConfirmed): 2,
+		string(rpc.ConfirmationStatusFinalized): 3,
+	}
+	return rank[string(status)] > 0 && rank[string(status)] >= rank[string(target)]
+}
+
+// tokenDelta returns the raw change of owner's balance of mint in a transaction.
+// For the native mint the fee payer's lamport change is used, excluding the network fee,
+// since wrapped SOL accounts are opened and closed within the swap. Lamports moved into
+// or out of owner's token accounts are added back, so rent for a token account opened or
+// closed by the swap doesn't count as SOL spent or received.
+func tokenDelta(tx *rpc.GetTransactionResult, owner solana.PublicKey, mint string) *big.Int {
+	delta := new(big.Int)
+	meta := tx.Meta
+
+	if mint == NativeMint {
+		if len(meta.PreBalances) == 0 || len(meta.PostBalances) == 0 {
+			return delta
+		}
+
+		accounts := map[uint16]bool{0: true}
+		for _, balances := range [][]rpc.TokenBalance{meta.PreTokenBalances, meta.PostTokenBalances} {
+			for _, bal := range balances {
+				if bal.Owner != nil && bal.Owner.Equals(owner) {
+					accounts[bal.AccountIndex] = true
+				}
+			}
+		}
+		for index := range accounts {
+			if int(index) >= len(meta.PreBalances) || int(index) >= len(meta.PostBalances) {
+				continue
+			}
+			delta.Add(delta, new(big.Int).SetUint64(meta.PostBalances[index]))
+			delta.Sub(delta, new(big.Int).SetUint64(meta.PreBalances[index]))
+		}
+		delta.Add(delta, new(big.Int).SetUint64(meta.Fee))
+		return delta
+	}
+
+	sum := func(balances []rpc.TokenBalance) *big.Int {
+		total := new(big.Int)
+		for _, bal := range balances {
+			if bal.Owner == nil || !bal.Owner.Equals(owner) || bal.Mint.String() != mint || bal.UiTokenAmount == nil {
+				continue
+			}
+			amount, ok := new(big.Int).SetString(bal.UiTokenAmount.Amount, 10)
+			if ok {
+				total.Add(total, amount)
+			}
+		}
+		return total
+	}
+
+	return delta.Sub(sum(meta.PostTokenBalances), sum(meta.PreTokenBalances))
+}
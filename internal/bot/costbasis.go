@@ -0,0 +1,190 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/magooney-loon/token-2022-refill-bot/internal/utils"
+)
+
+// Position is the cost basis of one held token, using average cost
+type Position struct {
+	Amount            float64   `json:"amount"`
+	CostUSD           float64   `json:"cost_usd"`
+	LastTakeProfitUSD float64   `json:"last_take_profit_usd"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// AverageCost returns the USD cost per token
+func (p Position) AverageCost() float64 {
+	if p.Amount <= 0 {
+		return 0
+	}
+	return p.CostUSD / p.Amount
+}
+
+// CostBasisBook persists the cost basis of every target token bought by the bot,
+// along with exit proceeds held back from being rebought
+type CostBasisBook struct {
+	mu   sync.Mutex
+	path string
+
+	Positions      map[string]Position `json:"positions"`
+	LockedProceeds float64             `json:"locked_proceeds"`
+	UpdatedAt      time.Time           `json:"updated_at"`
+}
+
+// LoadCostBasisBook loads the wallet's cost basis book, paper trades are kept in their own book
+func LoadCostBasisBook(wallet string, paper bool) (*CostBasisBook, error) {
+	cacheDir := "cache"
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return nil, err
+	}
+
+	name := fmt.Sprintf("cost_basis_%s.json", wallet[:8])
+	if paper {
+		name = fmt.Sprintf("cost_basis_paper_%s.json", wallet[:8])
+	}
+
+	book := &CostBasisBook{
+		path:      filepath.Join(cacheDir, name),
+		Positions: make(map[string]Position),
+	}
+
+	data, err := os.ReadFile(book.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return book, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, book); err != nil {
+		return nil, fmt.Errorf("failed to parse cost basis: %w", err)
+	}
+	if book.Positions == nil {
+		book.Positions = make(map[string]Position)
+	}
+
+	utils.Debug("Loaded cost basis", "path", book.path, "positions", len(book.Positions))
+	return book, nil
+}
+
+// Position returns the cost basis of a mint
+func (b *CostBasisBook) Position(mint string) Position {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.Positions[mint]
+}
+
+// Locked returns the exit proceeds held back from refills
+func (b *CostBasisBook) Locked() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.LockedProceeds
+}
+
+// Record updates the cost basis with a completed swap. Buys of a target add their input value
+// and network fee to its cost, sells reduce the cost in proportion to the amount sold.
+func (b *CostBasisBook) Record(result *SwapResult, prices map[string]float64, isTarget func(string) bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	value := result.InputAmount * prices[result.InputMint]
+
+	if isTarget(result.InputMint) {
+		position := b.Positions[result.InputMint]
+		if position.Amount > 0 {
+			sold := result.InputAmount / position.Amount
+			if sold > 1 {
+				sold = 1
+			}
+			position.Amount -= sold * position.Amount
+			position.CostUSD -= sold * position.CostUSD
+		}
+		if position.Amount <= 0 {
+			position = Position{}
+		}
+		position.UpdatedAt = result.Timestamp
+		b.Positions[result.InputMint] = position
+	}
+
+	if isTarget(result.OutputMint) && result.OutputAmount > 0 {
+		position := b.Positions[result.OutputMint]
+		position.Amount += result.OutputAmount
+		position.CostUSD += value + result.Fee*prices[NativeMint]
+		position.UpdatedAt = result.Timestamp
+		b.Positions[result.OutputMint] = position
+	}
+
+	return b.save()
+}
+
+// MarkTakeProfit records the price a take-profit was taken at so the next one needs a new high
+func (b *CostBasisBook) MarkTakeProfit(mint string, price float64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	position := b.Positions[mint]
+	position.LastTakeProfitUSD = price
+	b.Positions[mint] = position
+	return b.save()
+}
+
+// Lock holds back exit proceeds from being swapped by later refills, never more than balance
+func (b *CostBasisBook) Lock(amount, balance float64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.LockedProceeds = min(b.LockedProceeds+amount, max(balance, 0))
+	return b.save()
+}
+
+// Clamp shrinks the held proceeds to balance, so funds moved out of the wallet
+// stop being reserved. It returns the amount released.
+func (b *CostBasisBook) Clamp(balance float64) (float64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	balance = max(balance, 0)
+	if b.LockedProceeds <= balance {
+		return 0, nil
+	}
+	released := b.LockedProceeds - balance
+	b.LockedProceeds = balance
+	return released, b.save()
+}
+
+// Release makes all held proceeds available to refills again and returns the amount released
+func (b *CostBasisBook) Release() (float64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	released := b.LockedProceeds
+	if released == 0 {
+		return 0, nil
+	}
+	b.LockedProceeds = 0
+	return released, b.save()
+}
+
+// save writes the book to disk, the caller must hold the lock
+func (b *CostBasisBook) save() error {
+	b.UpdatedAt = time.Now()
+
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write to a temp file first so a crash can't corrupt the book
+	tmp := b.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, b.path)
+}
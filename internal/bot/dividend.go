@@ -0,0 +1,404 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/magooney-loon/token-2022-refill-bot/internal/utils"
+	"github.com/magooney-loon/token-2022-refill-bot/pkg/wallet"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/gagliardetto/solana-go/rpc/ws"
+)
+
+const (
+	// maxDividendCatchUp limits how many wallet transactions are scanned after reconnecting
+	maxDividendCatchUp = 100
+
+	// transactionLookupAttempts is how often a notified transaction is fetched before giving up
+	transactionLookupAttempts = 3
+)
+
+// DividendWatcher subscribes to the transactions mentioning the wallet and emits a trigger
+// for every SOL payout received from the dividend address. Payouts stay pending until the
+// bot settles them, and the last seen transaction never moves past a pending payout.
+type DividendWatcher struct {
+	mu              sync.Mutex
+	rpcClient       *rpc.Client
+	wsEndpoint      string
+	owner           solana.PublicKey
+	dividendAddress string
+	path            string
+	triggerChan     chan DividendTrigger
+	latest          solana.Signature // Newest transaction inspected
+
+	LastSignature solana.Signature          `json:"last_signature"`
+	Pending       []wallet.DividendTransfer `json:"pending,omitempty"`
+	Settled       []string                  `json:"settled,omitempty"` // Payouts settled while older ones are still pending
+}
+
+// NewDividendWatcher creates a watcher for the wallet, loading the last transaction it saw
+// so payouts received while the bot was down are caught up
+func NewDividendWatcher(rpcClient *rpc.Client, wsEndpoint string, owner solana.PublicKey, dividendAddress string, paper bool) (*DividendWatcher, error) {
+	if _, err := solana.PublicKeyFromBase58(dividendAddress); err != nil {
+		return nil, fmt.Errorf("invalid dividend address: %w", err)
+	}
+
+	cacheDir := "cache"
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return nil, err
+	}
+
+	wallet := owner.String()
+	name := fmt.Sprintf("dividend_watch_%s.json", wallet[:8])
+	if paper {
+		name = fmt.Sprintf("dividend_watch_paper_%s.json", wallet[:8])
+	}
+
+	watcher := &DividendWatcher{
+		rpcClient:       rpcClient,
+		wsEndpoint:      wsEndpoint,
+		owner:           owner,
+		dividendAddress: dividendAddress,
+		path:            filepath.Join(cacheDir, name),
+		triggerChan:     make(chan DividendTrigger, 1),
+	}
+
+	data, err := os.ReadFile(watcher.path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, watcher); err != nil {
+			return nil, fmt.Errorf("failed to parse dividend watcher state: %w", err)
+		}
+	}
+
+	return watcher, nil
+}
+
+// GetTriggerChannel returns the channel dividend triggers are sent on
+func (w *DividendWatcher) GetTriggerChannel() <-chan DividendTrigger {
+	return w.triggerChan
+}
+
+// Start keeps a logs subscription on the wallet open, reconnecting with backoff whenever the socket drops
+func (w *DividendWatcher) Start(ctx context.Context) error {
+	utils.Info("Starting dividend watcher",
+		"wallet", w.owner.String(),
+		"dividend_address", w.dividendAddress,
+		"endpoint", w.wsEndpoint)
+
+	delay := minReconnectDelay
+	for {
+		connected, err := w.run(ctx)
+		if ctx.Err() != nil {
+			utils.Info("Dividend watcher stopped by context")
+			return ctx.Err()
+		}
+
+		// A subscription that was up for a while starts over with a short delay
+		if connected {
+			delay = minReconnectDelay
+		}
+		utils.Warn("Dividend subscription dropped, reconnecting",
+			"error", err,
+			"retry_in", delay)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+	}
+}
+
+// run subscribes and handles notifications until the socket fails.
+// It reports whether the subscription was established.
+func (w *DividendWatcher) run(ctx context.Context) (bool, error) {
+	client, err := ws.Connect(ctx, w.wsEndpoint)
+	if err != nil {
+		return false, fmt.Errorf("failed to connect websocket: %w", err)
+	}
+	defer client.Close()
+
+	sub, err := client.LogsSubscribeMentions(w.owner, rpc.CommitmentConfirmed)
+	if err != nil {
+		return false, fmt.Errorf("failed to subscribe to %s: %w", w.owner, err)
+	}
+	defer sub.Unsubscribe()
+
+	utils.Info("Subscribed to wallet transactions", "wallet", w.owner.String())
+
+	// Catch any payout that arrived while the socket was down. Live notifications would move
+	// the last seen transaction past the ones missed, so a failed catch-up reconnects with backoff.
+	if err := w.catchUp(ctx); err != nil {
+		return false, fmt.Errorf("dividend catch-up failed: %w", err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return true, ctx.Err()
+		case err := <-sub.Err():
+			return true, err
+		case result, ok := <-sub.Response():
+			if !ok {
+				return true, fmt.Errorf("subscription closed")
+			}
+			if result == nil {
+				continue
+			}
+			if result.Value.Err == nil {
+				if err := w.inspect(ctx, result.Value.Signature); err != nil {
+					return true, err
+				}
+			}
+			w.advance(result.Value.Signature)
+		}
+	}
+}
+
+// catchUp inspects the wallet's transactions since the last one seen, oldest first.
+// On first start there is nothing to catch up and the newest transaction becomes the mark.
+func (w *DividendWatcher) catchUp(ctx context.Context) error {
+	w.mu.Lock()
+	last := w.LastSignature
+	w.mu.Unlock()
+
+	limit := maxDividendCatchUp
+	opts := &rpc.GetSignaturesForAddressOpts{
+		Limit:      &limit,
+		Until:      last,
+		Commitment: rpc.CommitmentConfirmed,
+	}
+	if last.IsZero() {
+		limit = 1
+	}
+
+	sigs, err := w.rpcClient.GetSignaturesForAddressWithOpts(ctx, w.owner, opts)
+	if err != nil {
+		return fmt.Errorf("failed to get signatures: %w", err)
+	}
+	if len(sigs) == 0 {
+		return nil
+	}
+
+	if last.IsZero() {
+		w.advance(sigs[0].Signature)
+		return nil
+	}
+
+	if len(sigs) == maxDividendCatchUp {
+		utils.Warn("More wallet transactions than the catch-up limit since the last seen one, older payouts are skipped",
+			"limit", maxDividendCatchUp)
+	}
+
+	for i := len(sigs) - 1; i >= 0; i-- {
+		if sigs[i].Err == nil {
+			if err := w.inspect(ctx, sigs[i].Signature); err != nil {
+				return err
+			}
+		}
+		w.advance(sigs[i].Signature)
+	}
+	return nil
+}
+
+// inspect fetches a transaction and emits a trigger if it paid a dividend into the wallet.
+// A transaction that can't be fetched returns the error before the last seen transaction
+// moves past it, so the catch-up after reconnecting inspects it again.
+func (w *DividendWatcher) inspect(ctx context.Context, sig solana.Signature) error {
+	tx, err := w.transaction(ctx, sig)
+	if err != nil {
+		return fmt.Errorf("failed to fetch wallet transaction %s: %w", sig, err)
+	}
+
+	amount := wallet.GetTransferAmount(tx, w.dividendAddress, w.owner.String())
+	if amount <= 0 {
+		return nil
+	}
+
+	transfer := wallet.DividendTransfer{
+		Signature: sig.String(),
+		Amount:    amount,
+		Timestamp: time.Now(),
+	}
+	if tx.BlockTime != nil {
+		transfer.Timestamp = tx.BlockTime.Time()
+	}
+
+	// The wallet's index among the account keys holds its balances
+	if solTx, err := tx.Transaction.GetTransaction(); err == nil {
+		for i, account := range solTx.Message.AccountKeys {
+			if account.Equals(w.owner) && i < len(tx.Meta.PreBalances) && i < len(tx.Meta.PostBalances) {
+				transfer.PreBalance = float64(tx.Meta.PreBalances[i]) / 1e9
+				transfer.PostBalance = float64(tx.Meta.PostBalances[i]) / 1e9
+				break
+			}
+		}
+	}
+
+	// Payouts seen again after a reconnect or restart are retried by the bot, not re-emitted
+	if !w.track(transfer) {
+		utils.Debug("Dividend payout already tracked", "signature", transfer.Signature)
+		return nil
+	}
+
+	utils.Info("Dividend payout received",
+		"signature", transfer.Signature,
+		"amount", amount,
+		"slot", tx.Slot)
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case w.triggerChan <- DividendTrigger{ID: "dividend-" + transfer.Signature, Transfer: transfer}:
+	}
+	return nil
+}
+
+// transaction fetches a notified transaction, retrying briefly since the node serving
+// the request may not have it yet
+func (w *DividendWatcher) transaction(ctx context.Context, sig solana.Signature) (*rpc.GetTransactionResult, error) {
+	version := uint64(0)
+
+	var lastErr error
+	for attempt := 0; attempt < transactionLookupAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(time.Duration(attempt) * time.Second):
+			}
+		}
+
+		tx, err := w.rpcClient.GetTransaction(ctx, sig, &rpc.GetTransactionOpts{
+			Commitment:                     rpc.CommitmentConfirmed,
+			MaxSupportedTransactionVersion: &version,
+		})
+		if err == nil && tx != nil && tx.Meta != nil {
+			return tx, nil
+		}
+		lastErr = err
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("transaction not found")
+	}
+	return nil, lastErr
+}
+
+// track adds a payout to the pending ones, false if it is already pending or settled
+func (w *DividendWatcher) track(transfer wallet.DividendTransfer) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.indexOf(transfer.Signature) >= 0 || slices.Contains(w.Settled, transfer.Signature) {
+		return false
+	}
+	w.Pending = append(w.Pending, transfer)
+	if err := w.save(); err != nil {
+		utils.Warn("Failed to save dividend watcher state", "error", err)
+	}
+	return true
+}
+
+// advance records sig as the newest transaction inspected. It becomes the last seen
+// transaction only while no payout is pending.
+func (w *DividendWatcher) advance(sig solana.Signature) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.latest = sig
+	if len(w.Pending) == 0 {
+		w.LastSignature = sig
+		w.Settled = nil
+	}
+	if err := w.save(); err != nil {
+		utils.Warn("Failed to save dividend watcher state", "error", err)
+	}
+}
+
+// PendingPayouts returns the payouts not settled yet, oldest first
+func (w *DividendWatcher) PendingPayouts() []wallet.DividendTransfer {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return slices.Clone(w.Pending)
+}
+
+// IsPending reports whether a payout still waits to be settled
+func (w *DividendWatcher) IsPending(signature string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.indexOf(signature) >= 0
+}
+
+// Bought keeps the buys made from a pending payout, so a retry only records them
+func (w *DividendWatcher) Bought(transfer wallet.DividendTransfer) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	i := w.indexOf(transfer.Signature)
+	if i < 0 {
+		return nil
+	}
+	transfer.Handled = true
+	w.Pending[i] = transfer
+	return w.save()
+}
+
+// Settle removes a payout from the pending ones once its buys are recorded. With nothing
+// left pending the last seen transaction catches up with the newest one inspected.
+func (w *DividendWatcher) Settle(signature string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	i := w.indexOf(signature)
+	if i < 0 {
+		return nil
+	}
+	w.Pending = slices.Delete(w.Pending, i, i+1)
+
+	if len(w.Pending) == 0 && !w.latest.IsZero() {
+		w.LastSignature = w.latest
+		w.Settled = nil
+	} else {
+		w.Settled = append(w.Settled, signature)
+	}
+	return w.save()
+}
+
+// indexOf returns the position of a pending payout, -1 if it isn't pending.
+// The caller must hold the lock.
+func (w *DividendWatcher) indexOf(signature string) int {
+	return slices.IndexFunc(w.Pending, func(transfer wallet.DividendTransfer) bool {
+		return transfer.Signature == signature
+	})
+}
+
+// save writes the last seen signature and the pending payouts to disk, the caller must hold the lock
+func (w *DividendWatcher) save() error {
+	data, err := json.MarshalIndent(w, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write to a temp file first so a crash can't corrupt the state
+	tmp := w.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, w.path)
+}
@@ -0,0 +1,104 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/magooney-loon/token-2022-refill-bot/internal/config"
+	"github.com/magooney-loon/token-2022-refill-bot/internal/utils"
+	"github.com/magooney-loon/token-2022-refill-bot/pkg/wallet"
+)
+
+// Exit reasons
+const (
+	ExitTakeProfit = "take_profit"
+	ExitStopLoss   = "stop_loss"
+)
+
+// ExitTrade is a sell of a held target triggered by a take-profit or stop-loss rule
+type ExitTrade struct {
+	Mint        string
+	Reason      string
+	Amount      float64 // Tokens to sell
+	Balance     float64 // Tokens held before the sell
+	Price       float64 // Current USD price
+	AverageCost float64 // USD cost per token
+	Change      float64 // Price change from average cost
+}
+
+// ExitManager evaluates take-profit and stop-loss rules against each target's cost basis
+type ExitManager struct {
+	config    *config.Config
+	book      *CostBasisBook
+	allocator *Allocator
+}
+
+// NewExitManager creates a new exit manager
+func NewExitManager(cfg *config.Config, book *CostBasisBook, allocator *Allocator) *ExitManager {
+	return &ExitManager{
+		config:    cfg,
+		book:      book,
+		allocator: allocator,
+	}
+}
+
+// Plan returns the exits triggered at current prices. A stop-loss sells the whole position;
+// a take-profit sells part of it and the next one needs the price to climb again from there.
+func (e *ExitManager) Plan(ctx context.Context) ([]ExitTrade, error) {
+	rules := e.config.Trading.Exits
+
+	holdings, err := e.allocator.holdings(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	mints := make([]string, 0, len(e.config.Token.Targets))
+	for _, target := range e.config.Token.Targets {
+		mints = append(mints, target.Mint)
+	}
+	prices, err := wallet.GetTokenPrices(ctx, e.config, mints)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get token prices: %w", err)
+	}
+
+	var trades []ExitTrade
+	for _, mint := range mints {
+		position := e.book.Position(mint)
+		averageCost := position.AverageCost()
+		held := holdings[mint]
+		price := prices[mint]
+		if averageCost <= 0 || held <= 0 || price <= 0 {
+			continue
+		}
+
+		trade := ExitTrade{
+			Mint:        mint,
+			Balance:     held,
+			Price:       price,
+			AverageCost: averageCost,
+			Change:      price/averageCost - 1,
+		}
+
+		utils.Debug("Evaluating exit rules",
+			"mint", mint,
+			"price", price,
+			"average_cost", averageCost,
+			"change", fmt.Sprintf("%.2f%%", trade.Change*100))
+
+		switch {
+		case rules.StopLossPercent > 0 && trade.Change <= -rules.StopLossPercent/100:
+			trade.Reason = ExitStopLoss
+			trade.Amount = held
+		case rules.TakeProfitPercent > 0 && price >= math.Max(averageCost, position.LastTakeProfitUSD)*(1+rules.TakeProfitPercent/100):
+			trade.Reason = ExitTakeProfit
+			trade.Amount = held * rules.TakeProfitSellPercent / 100
+		default:
+			continue
+		}
+
+		trades = append(trades, trade)
+	}
+
+	return trades, nil
+}
@@ -0,0 +1,288 @@
+package bot
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/magooney-loon/token-2022-refill-bot/internal/config"
+	"github.com/magooney-loon/token-2022-refill-bot/pkg/wallet"
+)
+
+// Export formats
+const (
+	ExportGeneric     = "generic"
+	ExportKoinly      = "koinly"
+	ExportCoinTracker = "cointracker"
+)
+
+// ExportFormats lists every supported export format
+var ExportFormats = []string{ExportGeneric, ExportKoinly, ExportCoinTracker}
+
+// dividendPriceWindow is how far from a payout a journaled SOL price may be to value it
+const dividendPriceWindow = 24 * time.Hour
+
+// exportRow is one taxable event, either a swap or a dividend transfer
+type exportRow struct {
+	Time           time.Time
+	Kind           string // swap or dividend
+	Reason         string
+	SentAsset      string
+	SentMint       string
+	SentAmount     float64
+	ReceivedAsset  string
+	ReceivedMint   string
+	ReceivedAmount float64
+	ValueUSD       float64 // 0 when no price was known at the time
+	FeeSOL         float64
+	FeeUSD         float64
+	Signature      string
+	Status         string
+}
+
+// exportFormat describes the columns of a CSV format
+type exportFormat struct {
+	header []string
+	row    func(exportRow) []string
+}
+
+var exportFormats = map[string]exportFormat{
+	ExportGeneric: {
+		header: []string{
+			"timestamp", "type", "reason",
+			"sent_asset", "sent_mint", "sent_quantity",
+			"received_asset", "received_mint", "received_quantity",
+			"usd_value", "fee_sol", "fee_usd", "signature", "status",
+		},
+		row: func(r exportRow) []string {
+			return []string{
+				r.Time.UTC().Format(time.RFC3339), r.Kind, r.Reason,
+				r.SentAsset, r.SentMint, formatQuantity(r.SentAmount),
+				r.ReceivedAsset, r.ReceivedMint, formatQuantity(r.ReceivedAmount),
+				formatUSD(r.ValueUSD), formatQuantity(r.FeeSOL), formatQuantity(r.FeeUSD), r.Signature, r.Status,
+			}
+		},
+	},
+	// Koinly universal format
+	ExportKoinly: {
+		header: []string{
+			"Date", "Sent Amount", "Sent Currency", "Received Amount", "Received Currency",
+			"Fee Amount", "Fee Currency", "Net Worth Amount", "Net Worth Currency",
+			"Label", "Description", "TxHash",
+		},
+		row: func(r exportRow) []string {
+			label := ""
+			if r.Kind == "dividend" {
+				label = "income"
+			}
+			return []string{
+				r.Time.UTC().Format("2006-01-02 15:04:05 UTC"),
+				formatQuantity(r.SentAmount), r.SentAsset,
+				formatQuantity(r.ReceivedAmount), r.ReceivedAsset,
+				formatQuantity(r.FeeSOL), feeCurrency(r.FeeSOL),
+				formatUSD(r.ValueUSD), valueCurrency(r.ValueUSD),
+				label, r.Reason, r.Signature,
+			}
+		},
+	},
+	// CoinTracker CSV import format
+	ExportCoinTracker: {
+		header: []string{
+			"Date", "Received Quantity", "Received Currency", "Sent Quantity", "Sent Currency",
+			"Fee Amount", "Fee Currency", "Tag",
+		},
+		row: func(r exportRow) []string {
+			tag := ""
+			if r.Kind == "dividend" {
+				tag = "payment"
+			}
+			return []string{
+				r.Time.UTC().Format("01/02/2006 15:04:05"),
+				formatQuantity(r.ReceivedAmount), r.ReceivedAsset,
+				formatQuantity(r.SentAmount), r.SentAsset,
+				formatQuantity(r.FeeSOL), feeCurrency(r.FeeSOL),
+				tag,
+			}
+		},
+	},
+}
+
+// ExportCSV writes every successful journaled swap and every cached dividend transfer of a
+// real wallet to a CSV file per format in the journal directory and returns their paths.
+// Paper trades are never exported.
+func ExportCSV(cfg *config.Config, walletAddr string, formats []string) ([]string, error) {
+	rows, err := exportRows(cfg, walletAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Join(cfg.Trading.JournalDir, "exports")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	stamp := time.Now().UTC().Format("20060102-150405")
+	paths := make([]string, 0, len(formats))
+	for _, name := range formats {
+		format, ok := exportFormats[name]
+		if !ok {
+			return paths, fmt.Errorf("unknown export format %q", name)
+		}
+
+		path := filepath.Join(dir, fmt.Sprintf("%s_%s_%s.csv", name, walletAddr[:8], stamp))
+		if err := writeCSV(path, format, rows); err != nil {
+			return paths, fmt.Errorf("failed to write %s export: %w", name, err)
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// exportRows collects swaps and dividends in time order
+func exportRows(cfg *config.Config, walletAddr string) ([]exportRow, error) {
+	entries, err := ReadJournal(JournalPath(cfg.Trading.JournalDir, walletAddr, false))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trade journal: %w", err)
+	}
+
+	var rows []exportRow
+	for _, entry := range entries {
+		if !entry.Succeeded() || entry.DryRun {
+			continue
+		}
+
+		value := entry.InputAmount * entry.InputPriceUSD
+		if value == 0 {
+			value = entry.OutputAmount * entry.OutputPriceUSD
+		}
+
+		rows = append(rows, exportRow{
+			Time:           entry.Time,
+			Kind:           "swap",
+			Reason:         entry.Reason,
+			SentAsset:      assetName(entry.InputSymbol, entry.InputMint),
+			SentMint:       entry.InputMint,
+			SentAmount:     entry.InputAmount,
+			ReceivedAsset:  assetName(entry.OutputSymbol, entry.OutputMint),
+			ReceivedMint:   entry.OutputMint,
+			ReceivedAmount: entry.OutputAmount,
+			ValueUSD:       value,
+			FeeSOL:         entry.Fee,
+			FeeUSD:         entry.Fee * entry.SOLPriceUSD,
+			Signature:      entry.Signature,
+			Status:         entry.Status,
+		})
+	}
+
+	transfers, err := wallet.GetDividendTransfers(walletAddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dividend cache: %w", err)
+	}
+	for _, transfer := range transfers {
+		rows = append(rows, exportRow{
+			Time:           transfer.Timestamp,
+			Kind:           "dividend",
+			Reason:         "dividend",
+			ReceivedAsset:  "SOL",
+			ReceivedMint:   NativeMint,
+			ReceivedAmount: transfer.Amount,
+			ValueUSD:       transfer.Amount * solPriceNear(entries, transfer.Timestamp),
+			Signature:      transfer.Signature,
+			Status:         "finalized",
+		})
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].Time.Before(rows[j].Time)
+	})
+	return rows, nil
+}
+
+// solPriceNear returns the SOL price journaled closest to t, or 0 if none is close enough
+func solPriceNear(entries []JournalEntry, t time.Time) float64 {
+	price := 0.0
+	best := dividendPriceWindow
+	for _, entry := range entries {
+		if entry.SOLPriceUSD == 0 {
+			continue
+		}
+		distance := entry.Time.Sub(t).Abs()
+		if distance <= best {
+			best = distance
+			price = entry.SOLPriceUSD
+		}
+	}
+	return price
+}
+
+// writeCSV writes rows in a format, through a temp file so a partial export is never left behind
+func writeCSV(path string, format exportFormat, rows []exportRow) error {
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(file)
+	if err := writer.Write(format.header); err != nil {
+		file.Close()
+		return err
+	}
+	for _, row := range rows {
+		if err := writer.Write(format.row(row)); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// assetName prefers the token symbol, falling back to the mint
+func assetName(symbol, mint string) string {
+	if symbol != "" {
+		return symbol
+	}
+	return mint
+}
+
+// formatQuantity renders an amount without exponent, empty when zero
+func formatQuantity(amount float64) string {
+	if amount == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(amount, 'f', -1, 64)
+}
+
+// formatUSD renders a USD value with cents precision, empty when unknown
+func formatUSD(value float64) string {
+	if value == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(value, 'f', 2, 64)
+}
+
+func feeCurrency(fee float64) string {
+	if fee == 0 {
+		return ""
+	}
+	return "SOL"
+}
+
+func valueCurrency(value float64) string {
+	if value == 0 {
+		return ""
+	}
+	return "USD"
+}
@@ -0,0 +1,127 @@
+package bot
+
+import (
+	"context"
+	"math"
+	"sort"
+
+	"github.com/magooney-loon/token-2022-refill-bot/internal/config"
+	"github.com/magooney-loon/token-2022-refill-bot/internal/jupiter"
+	"github.com/magooney-loon/token-2022-refill-bot/internal/utils"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// Priority fee strategies
+const (
+	FeeStrategyFixed      = "fixed"
+	FeeStrategyAuto       = "auto"
+	FeeStrategyPercentile = "percentile"
+)
+
+// autoLevels are Jupiter's priority levels in escalation order
+var autoLevels = []string{"medium", "high", "veryHigh"}
+
+// PriorityFeeEstimator decides the priority fee for each swap attempt
+type PriorityFeeEstimator struct {
+	rpcClient *rpc.Client
+	config    *config.PriorityFeeConfig
+}
+
+// NewPriorityFeeEstimator creates a new priority fee estimator
+func NewPriorityFeeEstimator(rpcClient *rpc.Client, cfg *config.PriorityFeeConfig) *PriorityFeeEstimator {
+	return &PriorityFeeEstimator{
+		rpcClient: rpcClient,
+		config:    cfg,
+	}
+}
+
+// Estimate returns the priority fee for a swap attempt; attempt 0 is the first try
+// and every retry escalates the fee
+func (e *PriorityFeeEstimator) Estimate(ctx context.Context, quote *jupiter.Quote, attempt int) jupiter.PriorityFee {
+	escalation := math.Pow(e.config.EscalationMultiplier, float64(attempt))
+
+	switch e.config.Strategy {
+	case FeeStrategyAuto:
+		level := 0
+		for i, name := range autoLevels {
+			if name == e.config.AutoLevel {
+				level = i
+			}
+		}
+		level += attempt
+		if level >= len(autoLevels) {
+			level = len(autoLevels) - 1
+		}
+		return jupiter.PriorityFee{
+			AutoLevel:   autoLevels[level],
+			MaxLamports: e.config.MaxLamports,
+		}
+
+	case FeeStrategyPercentile:
+		price, err := e.percentileFee(ctx, quote)
+		if err != nil {
+			utils.Warn("Failed to get recent prioritization fees, falling back to fixed fee", "error", err)
+			break
+		}
+		return jupiter.PriorityFee{
+			MicroLamportsPerCU: uint64(float64(price) * escalation),
+			MaxLamports:        e.config.MaxLamports,
+		}
+	}
+
+	lamports := uint64(float64(e.config.FixedLamports) * escalation)
+	if e.config.MaxLamports > 0 && lamports > e.config.MaxLamports {
+		lamports = e.config.MaxLamports
+	}
+	return jupiter.PriorityFee{Lamports: lamports}
+}
+
+// percentileFee returns the configured percentile of recent compute unit prices
+// paid for transactions touching the quote's route accounts
+func (e *PriorityFeeEstimator) percentileFee(ctx context.Context, quote *jupiter.Quote) (uint64, error) {
+	seen := make(map[string]bool)
+	var accounts solana.PublicKeySlice
+	add := func(addr string) {
+		if seen[addr] {
+			return
+		}
+		seen[addr] = true
+		if key, err := solana.PublicKeyFromBase58(addr); err == nil {
+			accounts = append(accounts, key)
+		}
+	}
+	for _, route := range quote.RoutePlan {
+		add(route.SwapInfo.AmmKey)
+	}
+	add(quote.InputMint)
+	add(quote.OutputMint)
+
+	results, err := e.rpcClient.GetRecentPrioritizationFees(ctx, accounts)
+	if err != nil {
+		return 0, err
+	}
+
+	fees := make([]uint64, 0, len(results))
+	for _, result := range results {
+		fees = append(fees, result.PrioritizationFee)
+	}
+	if len(fees) == 0 {
+		return 0, nil
+	}
+	sort.Slice(fees, func(i, j int) bool { return fees[i] < fees[j] })
+
+	index := int(math.Ceil(float64(e.config.Percentile)/100*float64(len(fees)))) - 1
+	if index < 0 {
+		index = 0
+	}
+
+	utils.Debug("Recent prioritization fees",
+		"accounts", len(accounts),
+		"samples", len(fees),
+		"percentile", e.config.Percentile,
+		"fee", fees[index])
+
+	return fees[index], nil
+}
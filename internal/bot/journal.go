@@ -0,0 +1,188 @@
+package bot
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/magooney-loon/token-2022-refill-bot/internal/jupiter"
+	"github.com/magooney-loon/token-2022-refill-bot/internal/utils"
+)
+
+// Reasons a swap was made, recorded in the trade journal
+const (
+	TradeReasonThreshold = "threshold"
+	TradeReasonSchedule  = "schedule"
+	TradeReasonDividend  = "dividend"
+	TradeReasonTWAP      = "twap"
+	TradeReasonRebalance = "rebalance"
+)
+
+// Journal statuses besides the commitment level a swap was confirmed at
+const (
+	JournalFailed    = "failed"
+	JournalRecovered = "recovered"
+)
+
+// JournalEntry is one swap outcome in the trade journal
+type JournalEntry struct {
+	Time    time.Time `json:"time"`
+	Trigger string    `json:"trigger,omitempty"`
+	Reason  string    `json:"reason,omitempty"`
+	Status  string    `json:"status"`
+	DryRun  bool      `json:"dry_run,omitempty"`
+	Error   string    `json:"error,omitempty"`
+
+	InputMint    string  `json:"input_mint"`
+	OutputMint   string  `json:"output_mint"`
+	InputSymbol  string  `json:"input_symbol,omitempty"`
+	OutputSymbol string  `json:"output_symbol,omitempty"`
+	Requested    float64 `json:"requested,omitempty"` // Input amount the order asked to swap
+
+	// Quote the swap was built from, including its route plan
+	Quote         *jupiter.Quote `json:"quote,omitempty"`
+	QuotedInput   float64        `json:"quoted_input,omitempty"`
+	QuotedOutput  float64        `json:"quoted_output,omitempty"`
+	MinimumOutput float64        `json:"minimum_output,omitempty"`
+	Route         string         `json:"route,omitempty"`
+	PriceImpact   float64        `json:"price_impact"`
+
+	// What actually happened on chain
+	Signature    string  `json:"signature,omitempty"`
+	Slot         uint64  `json:"slot,omitempty"`
+	InputAmount  float64 `json:"input_amount"`
+	OutputAmount float64 `json:"output_amount"`
+	Fee          float64 `json:"fee"`
+	PriorityFee  float64 `json:"priority_fee"`
+
+	// USD prices when the swap was recorded
+	InputPriceUSD  float64 `json:"input_price_usd,omitempty"`
+	OutputPriceUSD float64 `json:"output_price_usd,omitempty"`
+	SOLPriceUSD    float64 `json:"sol_price_usd,omitempty"`
+}
+
+// Succeeded reports whether the entry is a swap that landed or was simulated
+func (e *JournalEntry) Succeeded() bool {
+	return e.Status != JournalFailed && e.Signature != ""
+}
+
+// Journal is an append-only JSONL file of swap outcomes. Every entry is written with a
+// single write and synced, so a crash loses at most the line being written.
+type Journal struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+// JournalPath returns where a wallet's trades are journaled, paper trades have their own file
+func JournalPath(dir, wallet string, paper bool) string {
+	name := fmt.Sprintf("trades_%s.jsonl", wallet[:8])
+	if paper {
+		name = fmt.Sprintf("trades_paper_%s.jsonl", wallet[:8])
+	}
+	return filepath.Join(dir, name)
+}
+
+// OpenJournal opens the wallet's trade journal for appending, creating it if needed
+func OpenJournal(dir, wallet string, paper bool) (*Journal, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	path := JournalPath(dir, wallet, paper)
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open trade journal: %w", err)
+	}
+
+	// A crash mid-write leaves a partial last line, start the next entry on a fresh one
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := file.ReadAt(last, info.Size()-1); err != nil {
+			file.Close()
+			return nil, err
+		}
+		if last[0] != '\n' {
+			if _, err := file.Write([]byte("\n")); err != nil {
+				file.Close()
+				return nil, err
+			}
+		}
+	}
+
+	utils.Debug("Trade journal opened", "path", path)
+	return &Journal{path: path, file: file}, nil
+}
+
+// Append writes an entry to the end of the journal
+func (j *Journal) Append(entry JournalEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if _, err := j.file.Write(data); err != nil {
+		return err
+	}
+	return j.file.Sync()
+}
+
+// Path returns the journal's file path
+func (j *Journal) Path() string {
+	return j.path
+}
+
+// Close closes the journal file
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.file.Close()
+}
+
+// ReadJournal returns every entry of a journal file in the order written. A missing
+// journal has no entries, and lines left partial by a crash are skipped.
+func ReadJournal(path string) ([]JournalEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []JournalEntry
+	reader := bufio.NewReader(file)
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(data)) > 0 {
+			var entry JournalEntry
+			if jsonErr := json.Unmarshal(data, &entry); jsonErr != nil {
+				utils.Warn("Skipping unreadable trade journal line", "path", path, "line", line, "error", jsonErr)
+			} else {
+				entries = append(entries, entry)
+			}
+		}
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
@@ -3,6 +3,7 @@ package bot
 import (
 	"context"
 	"fmt"
+	"sync/atomic"
 	"time"
 
 	"github.com/magooney-loon/token-2022-refill-bot/internal/utils"
@@ -19,6 +20,13 @@ type Monitor struct {
 	checkInterval time.Duration
 	stopChan      chan struct{}
 	resultChan    chan BalanceCheck
+	recheckChan   chan struct{}
+	ledger        *PaperLedger
+	wsEndpoint    string
+	socketUp      atomic.Bool
+	lastAmount    atomic.Uint64 // Raw balance of the latest check
+	mint          string
+	account       *WatchedAccount
 }
 
 // NewMonitor creates a new balance monitor
@@ -35,13 +43,22 @@ func NewMonitor(
 		checkInterval: checkInterval,
 		stopChan:      make(chan struct{}),
 		resultChan:    make(chan BalanceCheck, 1),
+		recheckChan:   make(chan struct{}, 1),
 	}
 }
 
 // Start begins the balance monitoring process
 func (m *Monitor) Start(ctx context.Context) error {
+	account, err := ResolveWatchedAccount(ctx, m.rpcClient, m.wallet.PublicKey(), m.mint)
+	if err != nil {
+		return fmt.Errorf("failed to resolve watched account: %w", err)
+	}
+	m.account = account
+
 	utils.Info("Starting balance monitor",
 		"wallet", m.wallet.PublicKey().String(),
+		"mint", account.Mint,
+		"account", account.Address.String(),
 		"minBalance", m.minBalance,
 		"interval", m.checkInterval)
 
@@ -54,6 +71,13 @@ func (m *Monitor) Start(ctx context.Context) error {
 		return err
 	}
 
+	// Balance changes arrive over the websocket as they happen. The ticker keeps running so
+	// price-driven checks (exits, rebalancing, held refills) still get evaluated; it only
+	// polls the RPC while the socket is down and reuses the latest balance otherwise.
+	if m.wsEndpoint != "" {
+		go m.subscribe(ctx)
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -62,7 +86,15 @@ func (m *Monitor) Start(ctx context.Context) error {
 		case <-m.stopChan:
 			utils.Info("Monitor stopped by request")
 			return nil
+		case <-m.recheckChan:
+			if err := m.checkBalance(ctx); err != nil {
+				utils.Error("Balance check failed", err)
+			}
 		case <-ticker.C:
+			if m.socketUp.Load() {
+				m.report(m.lastAmount.Load())
+				continue
+			}
 			if err := m.checkBalance(ctx); err != nil {
 				utils.Error("Balance check failed", err)
 				// Don't return, keep trying
@@ -76,6 +108,14 @@ func (m *Monitor) Stop() {
 	close(m.stopChan)
 }
 
+// Recheck requests a balance check outside the regular interval
+func (m *Monitor) Recheck() {
+	select {
+	case m.recheckChan <- struct{}{}:
+	default:
+	}
+}
+
 // GetResultChannel returns the channel for balance check results
 func (m *Monitor) GetResultChannel() <-chan BalanceCheck {
 	return m.resultChan
@@ -83,40 +123,86 @@ func (m *Monitor) GetResultChannel() <-chan BalanceCheck {
 
 // checkBalance performs a single balance check
 func (m *Monitor) checkBalance(ctx context.Context) error {
-	utils.Debug("Checking SOL balance", "wallet", m.wallet.PublicKey().String())
+	utils.Debug("Checking balance",
+		"wallet", m.wallet.PublicKey().String(),
+		"mint", m.account.Mint)
 
-	balance, err := m.rpcClient.GetBalance(
-		ctx,
-		m.wallet.PublicKey(),
-		rpc.CommitmentFinalized,
-	)
+	amount, err := m.account.Fetch(ctx, m.rpcClient, rpc.CommitmentFinalized)
 	if err != nil {
 		return fmt.Errorf("failed to get balance: %w", err)
 	}
 
-	// Convert lamports to SOL
-	solBalance := float64(balance.Value) / float64(solana.LAMPORTS_PER_SOL)
+	m.report(amount)
+	return nil
+}
+
+// report converts a raw balance into a BalanceCheck and sends it to the bot
+func (m *Monitor) report(amount uint64) {
+	var err error
+	m.lastAmount.Store(amount)
+
+	// Convert to human units
+	balance := m.account.UIAmount(amount)
+
+	// In dry-run mode the virtual balance drives the trader
+	if m.ledger != nil {
+		realBalance := balance
+		balance, err = m.ledger.Sync(m.account.Mint, realBalance)
+		if err != nil {
+			utils.Warn("Failed to save paper ledger", "error", err)
+		}
+		utils.Debug("Using paper balance",
+			"real_balance", realBalance,
+			"paper_balance", balance)
+	}
 
+	now := time.Now()
 	result := BalanceCheck{
-		Balance:   solBalance,
-		Timestamp: time.Now(),
-		MetTarget: solBalance >= m.minBalance,
+		ID:        fmt.Sprintf("balance-%d", now.UnixNano()),
+		Mint:      m.account.Mint,
+		Amount:    amount,
+		Balance:   balance,
+		Timestamp: now,
+		MetTarget: balance >= m.minBalance,
 		Error:     nil,
 	}
 
 	utils.Debug("Balance check complete",
-		"balance", solBalance,
+		"mint", m.account.Mint,
+		"balance", balance,
 		"minBalance", m.minBalance,
 		"metTarget", result.MetTarget)
 
-	// Send result on channel (non-blocking)
+	// Send result on channel (non-blocking), a newer balance replaces one still waiting
 	select {
 	case m.resultChan <- result:
 	default:
-		utils.Warn("Result channel full, skipping update")
+		select {
+		case <-m.resultChan:
+			utils.Debug("Replaced pending balance check with a newer one")
+		default:
+		}
+		select {
+		case m.resultChan <- result:
+		default:
+			utils.Warn("Result channel full, skipping update")
+		}
 	}
+}
 
-	return nil
+// SetPaperLedger makes the monitor report the ledger's virtual balance
+func (m *Monitor) SetPaperLedger(ledger *PaperLedger) {
+	m.ledger = ledger
+}
+
+// SetMint makes the monitor watch the wallet's balance of mint instead of SOL
+func (m *Monitor) SetMint(mint string) {
+	m.mint = mint
+}
+
+// SetWebsocket makes the monitor subscribe to the wallet account for real-time balance checks
+func (m *Monitor) SetWebsocket(endpoint string) {
+	m.wsEndpoint = endpoint
 }
 
 // UpdateMinBalance updates the minimum balance threshold
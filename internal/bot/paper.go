@@ -0,0 +1,197 @@
+package bot
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/magooney-loon/token-2022-refill-bot/internal/utils"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+// PaperLedger holds the virtual balances used in dry-run mode.
+// Real inflows and outflows of the watched mint are mirrored into it so dividends keep triggering buys.
+type PaperLedger struct {
+	mu   sync.Mutex
+	path string
+
+	Balances         map[string]float64 `json:"balances"`
+	LastRealBalances map[string]float64 `json:"last_real_balances"`
+	Trades           int                `json:"trades"`
+	CreatedAt        time.Time          `json:"created_at"`
+	UpdatedAt        time.Time          `json:"updated_at"`
+}
+
+// LoadPaperLedger loads the wallet's paper ledger, creating one seeded with startBalance
+// of the input mint if none exists yet. A zero startBalance seeds from the real balance.
+func LoadPaperLedger(wallet, inputMint string, startBalance float64) (*PaperLedger, error) {
+	cacheDir := "cache"
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return nil, err
+	}
+
+	ledger := &PaperLedger{
+		path:             filepath.Join(cacheDir, fmt.Sprintf("paper_ledger_%s.json", wallet[:8])),
+		Balances:         make(map[string]float64),
+		LastRealBalances: make(map[string]float64),
+	}
+
+	data, err := os.ReadFile(ledger.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+		ledger.CreatedAt = time.Now()
+		if startBalance > 0 {
+			ledger.Balances[inputMint] = startBalance
+		}
+		utils.Info("Created paper ledger", "path", ledger.path, "start_balance", startBalance)
+		return ledger, nil
+	}
+
+	if err := json.Unmarshal(data, ledger); err != nil {
+		return nil, fmt.Errorf("failed to parse paper ledger: %w", err)
+	}
+	if ledger.Balances == nil {
+		ledger.Balances = make(map[string]float64)
+	}
+	if ledger.LastRealBalances == nil {
+		ledger.LastRealBalances = make(map[string]float64)
+	}
+
+	utils.Info("Loaded paper ledger",
+		"path", ledger.path,
+		"input_balance", ledger.Balances[inputMint],
+		"trades", ledger.Trades)
+
+	return ledger, nil
+}
+
+// Balance returns the virtual balance of a mint
+func (l *PaperLedger) Balance(mint string) float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.Balances[mint]
+}
+
+// Sync mirrors the change in the real balance of mint since the last sync into the
+// virtual balance and returns the virtual balance
+func (l *PaperLedger) Sync(mint string, realBalance float64) (float64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	last, synced := l.LastRealBalances[mint]
+	switch {
+	case !synced && l.Balances[mint] == 0:
+		// No seed configured, start from the real balance
+		l.Balances[mint] = realBalance
+	case synced:
+		if delta := realBalance - last; delta != 0 {
+			l.Balances[mint] += delta
+			utils.Debug("Mirrored real balance change into paper ledger",
+				"mint", mint,
+				"delta", delta,
+				"virtual_balance", l.Balances[mint])
+		}
+	}
+	l.LastRealBalances[mint] = realBalance
+
+	return l.Balances[mint], l.save()
+}
+
+// Apply books a simulated swap against the virtual balances
+func (l *PaperLedger) Apply(result *SwapResult) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.Balances[result.InputMint] -= result.InputAmount
+	l.Balances[NativeMint] -= result.Fee
+	l.Balances[result.OutputMint] += result.OutputAmount
+	l.Trades++
+
+	utils.Info("Paper ledger updated",
+		"sol_balance", l.Balances[NativeMint],
+		"output_balance", l.Balances[result.OutputMint],
+		"trades", l.Trades)
+
+	return l.save()
+}
+
+// save writes the ledger to disk, the caller must hold the lock
+func (l *PaperLedger) save() error {
+	l.UpdatedAt = time.Now()
+
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write to a temp file first so a crash can't corrupt the ledger
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, l.path)
+}
+
+// executePaperSwap books the simulated swap against the paper ledger instead of sending it
+func (t *Trader) executePaperSwap(ctx context.Context, triggerID string, attempt *swapAttempt) (*SwapResult, error) {
+	swapTx := attempt.swapTx
+
+	// Dry runs are never signed, the message hash stands in for the signature
+	message, err := swapTx.Transaction.Message.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode swap transaction: %w", err)
+	}
+	id := solana.Hash(sha256.Sum256(message))
+
+	// Base fee per signature plus the priority fee Jupiter attached
+	feeLamports := baseFeeLamports*uint64(swapTx.Transaction.Message.Header.NumRequiredSignatures) + swapTx.PrioritizationFee
+
+	result := &SwapResult{
+		InputMint:    attempt.quote.InputMint,
+		OutputMint:   attempt.quote.OutputMint,
+		InputAmount:  t.fromRawAmount(attempt.quote.InAmount, attempt.inputToken.Decimals),
+		OutputAmount: attempt.expectedOutput,
+		QuotedOutput: attempt.expectedOutput,
+		Fee:          float64(feeLamports) / float64(solana.LAMPORTS_PER_SOL),
+		PriorityFee:  float64(swapTx.PrioritizationFee) / float64(solana.LAMPORTS_PER_SOL),
+		Timestamp:    time.Now(),
+		TxSignature:  "dry-run-" + id.String(),
+		Status:       StatusSimulated,
+		Route:        routeLabel(attempt.quote),
+		PriceImpact:  attempt.priceImpact,
+	}
+
+	// The swap was simulated and checked against the quote unless the real wallet couldn't
+	// cover it, in which case it is booked from the quote alone
+	unitsConsumed := uint64(0)
+	if sim := swapTx.Simulation; sim != nil && sim.UnitsConsumed != nil {
+		unitsConsumed = *sim.UnitsConsumed
+	}
+
+	utils.Info("[DRY RUN] Swap simulated",
+		"trigger", triggerID,
+		"input_amount", fmt.Sprintf("%.6f %s", result.InputAmount, attempt.inputToken.Symbol),
+		"output_amount", fmt.Sprintf("%.6f %s", result.OutputAmount, attempt.outputToken.Symbol),
+		"estimated_fee", fmt.Sprintf("%.9f SOL", result.Fee),
+		"priority_fee", fmt.Sprintf("%.9f SOL", result.PriorityFee),
+		"simulated", swapTx.Simulation != nil,
+		"compute_units", unitsConsumed,
+		"route", result.Route,
+		"price_impact", fmt.Sprintf("%.2f%%", result.PriceImpact*100))
+
+	if err := t.ledger.Apply(result); err != nil {
+		return nil, fmt.Errorf("failed to update paper ledger: %w", err)
+	}
+
+	t.guard.Complete(triggerID, result)
+	t.record(ctx, JournalEntry{Trigger: triggerID, Reason: attempt.reason}, attempt, result)
+	return result, nil
+}
@@ -0,0 +1,332 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/magooney-loon/token-2022-refill-bot/internal/config"
+	"github.com/magooney-loon/token-2022-refill-bot/internal/utils"
+	"github.com/magooney-loon/token-2022-refill-bot/pkg/token2022"
+	"github.com/magooney-loon/token-2022-refill-bot/pkg/wallet"
+
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// Cost basis methods for the P&L engine
+const (
+	PnLAverageCost = "average"
+	PnLFIFO        = "fifo"
+)
+
+// pnlLot is a quantity of a token bought at one USD cost
+type pnlLot struct {
+	amount  float64
+	costUSD float64
+}
+
+// PnLToken is the profit and loss of one token the bot traded, in USD
+type PnLToken struct {
+	Mint          string
+	Symbol        string
+	Amount        float64 // Held according to the journal
+	CostBasis     float64 // Cost of the amount held
+	AverageCost   float64 // Cost per token held
+	Price         float64
+	MarketValue   float64
+	Unrealized    float64
+	Realized      float64 // Gains on sells
+	Untracked     float64 // Amount sold beyond what the journal shows bought, left out of realized P&L
+	TotalCost     float64 // Cost of everything bought, including amounts sold since
+	Dividends     float64 // Dividend income credited to this token
+	DividendYield float64 // Dividends as a percent of the total cost
+
+	lots []pnlLot
+}
+
+// PnLReport is the profit and loss of everything the bot traded, in USD
+type PnLReport struct {
+	Method            string
+	Tokens            []*PnLToken
+	CostBasis         float64
+	TotalCost         float64
+	MarketValue       float64
+	Unrealized        float64
+	Realized          float64
+	DividendsSOL      float64
+	Dividends         float64
+	DividendYield     float64
+	TotalReturn       float64 // Unrealized and realized P&L plus dividends
+	Unpriced          int     // Journaled swaps without USD prices, left out of the cost basis
+	UnpricedDividends int     // Payouts without a journaled SOL price nearby, valued at the current price
+}
+
+// ComputePnL replays successful journaled swaps against the cost basis method. The tokens
+// the bot spends are its cash, so only the tokens they were swapped into are tracked. Buy
+// costs and sell proceeds include the network fee, which a swap between two tracked tokens
+// only adds to the cost of the token bought.
+func ComputePnL(entries []JournalEntry, isCash func(string) bool, method string, prices map[string]float64) (*PnLReport, error) {
+	if method != PnLAverageCost && method != PnLFIFO {
+		return nil, fmt.Errorf("unknown cost basis method %q", method)
+	}
+
+	report := &PnLReport{Method: method}
+	tokens := make(map[string]*PnLToken)
+	token := func(mint, symbol string) *PnLToken {
+		t, ok := tokens[mint]
+		if !ok {
+			t = &PnLToken{Mint: mint}
+			tokens[mint] = t
+		}
+		if symbol != "" {
+			t.Symbol = symbol
+		}
+		return t
+	}
+
+	for _, entry := range entries {
+		if !entry.Succeeded() {
+			continue
+		}
+		fee := entry.Fee * entry.SOLPriceUSD
+
+		// A swap between two tracked tokens sells one and buys the other at the same value
+		value := entry.InputAmount * entry.InputPriceUSD
+		if value == 0 {
+			value = entry.OutputAmount * entry.OutputPriceUSD
+		}
+		if value == 0 {
+			report.Unpriced++
+			continue
+		}
+
+		if !isCash(entry.InputMint) {
+			proceeds := value - fee
+			if !isCash(entry.OutputMint) {
+				proceeds = value
+			}
+			token(entry.InputMint, entry.InputSymbol).sell(entry.InputAmount, proceeds, method)
+		}
+		if !isCash(entry.OutputMint) {
+			token(entry.OutputMint, entry.OutputSymbol).buy(entry.OutputAmount, value+fee, method)
+		}
+	}
+
+	for _, t := range tokens {
+		t.Price = prices[t.Mint]
+		t.MarketValue = t.Amount * t.Price
+		t.Unrealized = t.MarketValue - t.CostBasis
+		if t.Amount > 0 {
+			t.AverageCost = t.CostBasis / t.Amount
+		}
+
+		report.Tokens = append(report.Tokens, t)
+		report.CostBasis += t.CostBasis
+		report.TotalCost += t.TotalCost
+		report.MarketValue += t.MarketValue
+		report.Unrealized += t.Unrealized
+		report.Realized += t.Realized
+	}
+
+	sort.Slice(report.Tokens, func(i, j int) bool {
+		return report.Tokens[i].CostBasis > report.Tokens[j].CostBasis
+	})
+
+	report.TotalReturn = report.Unrealized + report.Realized
+	return report, nil
+}
+
+// buy adds a purchase to the token's cost basis
+func (t *PnLToken) buy(amount, costUSD float64, method string) {
+	if amount <= 0 {
+		return
+	}
+	t.Amount += amount
+	t.CostBasis += costUSD
+	t.TotalCost += costUSD
+	if method == PnLFIFO {
+		t.lots = append(t.lots, pnlLot{amount: amount, costUSD: costUSD})
+	}
+}
+
+// sell realizes the gain on amount sold for proceedsUSD, taking the cost of the oldest lots
+// with FIFO or the average cost otherwise
+func (t *PnLToken) sell(amount, proceedsUSD float64, method string) {
+	if amount <= 0 {
+		return
+	}
+
+	matched := min(amount, t.Amount)
+	if matched < amount {
+		t.Untracked += amount - matched
+	}
+	if matched <= 0 {
+		return
+	}
+
+	var cost float64
+	if method == PnLFIFO {
+		remaining := matched
+		for remaining > 0 && len(t.lots) > 0 {
+			lot := &t.lots[0]
+			take := min(remaining, lot.amount)
+			share := lot.costUSD * take / lot.amount
+			cost += share
+			lot.amount -= take
+			lot.costUSD -= share
+			remaining -= take
+			if lot.amount <= 0 {
+				t.lots = t.lots[1:]
+			}
+		}
+	} else {
+		cost = t.CostBasis * matched / t.Amount
+	}
+
+	t.Realized += proceedsUSD*matched/amount - cost
+	t.Amount -= matched
+	t.CostBasis -= cost
+	if t.Amount <= 0 {
+		t.Amount = 0
+		t.CostBasis = 0
+		t.lots = nil
+	}
+}
+
+// creditDividends attributes dividend income to a token and the report. The yield is on the
+// total cost, so selling part of a position doesn't inflate it.
+func (r *PnLReport) creditDividends(mint string, amountSOL, valueUSD float64) {
+	r.DividendsSOL = amountSOL
+	r.Dividends = valueUSD
+
+	for _, t := range r.Tokens {
+		if t.Mint == mint {
+			t.Dividends = r.Dividends
+			if t.TotalCost > 0 {
+				t.DividendYield = t.Dividends / t.TotalCost * 100
+			}
+		}
+	}
+	if r.TotalCost > 0 {
+		r.DividendYield = r.Dividends / r.TotalCost * 100
+	}
+	r.TotalReturn = r.Unrealized + r.Realized + r.Dividends
+}
+
+// valueDividends sums payouts in SOL and in USD at the SOL price journaled closest to each
+// one. Payouts without a journaled price nearby are valued at solPrice and counted.
+func valueDividends(transfers []wallet.DividendTransfer, entries []JournalEntry, solPrice float64) (amountSOL, valueUSD float64, unpriced int) {
+	for _, transfer := range transfers {
+		price := solPriceNear(entries, transfer.Timestamp)
+		if price == 0 {
+			price = solPrice
+			unpriced++
+		}
+		amountSOL += transfer.Amount
+		valueUSD += transfer.Amount * price
+	}
+	return amountSOL, valueUSD, unpriced
+}
+
+// LoadPnL computes a wallet's P&L from its trade journal, current prices and, when a dividend
+// address is configured, its dividend history. Each dividend is valued at the SOL price when
+// it was received and credited to the primary target, the token paying them. Dividends are
+// real income, so they are left out of the paper P&L.
+func LoadPnL(ctx context.Context, cfg *config.Config, rpcClient *rpc.Client, tokenClient *token2022.Client, walletAddr string, paper bool) (*PnLReport, error) {
+	entries, err := ReadJournal(JournalPath(cfg.Trading.JournalDir, walletAddr, paper))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trade journal: %w", err)
+	}
+
+	mints := []string{NativeMint}
+	seen := map[string]bool{NativeMint: true}
+	for _, entry := range entries {
+		for _, mint := range []string{entry.InputMint, entry.OutputMint} {
+			if !seen[mint] {
+				seen[mint] = true
+				mints = append(mints, mint)
+			}
+		}
+	}
+
+	prices, err := wallet.GetTokenPrices(ctx, cfg, mints)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get token prices: %w", err)
+	}
+
+	report, err := ComputePnL(entries, cfg.IsCash, cfg.Trading.PnLMethod, prices)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Token.DividendMint != "" && !paper {
+		if err := creditDividendHistory(ctx, cfg, rpcClient, tokenClient, walletAddr, entries, prices[NativeMint], report); err != nil {
+			utils.Warn("Failed to get dividend history, P&L excludes dividends", "error", err)
+		}
+	}
+
+	return report, nil
+}
+
+// creditDividendHistory brings the dividend cache up to date and credits its payouts to the report
+func creditDividendHistory(ctx context.Context, cfg *config.Config, rpcClient *rpc.Client, tokenClient *token2022.Client, walletAddr string, entries []JournalEntry, solPrice float64, report *PnLReport) error {
+	if _, err := wallet.GetDividendHistory(ctx, cfg, rpcClient, tokenClient, walletAddr); err != nil {
+		return err
+	}
+	transfers, err := wallet.GetDividendTransfers(walletAddr)
+	if err != nil {
+		return err
+	}
+
+	amountSOL, valueUSD, unpriced := valueDividends(transfers, entries, solPrice)
+	report.UnpricedDividends = unpriced
+	report.creditDividends(cfg.Token.OutputMint, amountSOL, valueUSD)
+	return nil
+}
+
+// DisplayPnL shows a wallet's P&L in a formatted UI
+func DisplayPnL(ctx context.Context, cfg *config.Config, rpcClient *rpc.Client, tokenClient *token2022.Client, walletAddr string, paper bool) error {
+	report, err := LoadPnL(ctx, cfg, rpcClient, tokenClient, walletAddr, paper)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\n💹 Profit & Loss (%s cost)\n", report.Method)
+	fmt.Println("-------------------")
+	fmt.Printf("• Cost Basis: $%.2f\n", report.CostBasis)
+	fmt.Printf("• Market Value: $%.2f\n", report.MarketValue)
+	fmt.Printf("• Unrealized P&L: $%.2f\n", report.Unrealized)
+	fmt.Printf("• Realized P&L: $%.2f\n", report.Realized)
+	if report.DividendsSOL > 0 {
+		fmt.Printf("• Dividends: %.6f SOL ($%.2f, %.2f%% yield on total cost)\n", report.DividendsSOL, report.Dividends, report.DividendYield)
+		if report.UnpricedDividends > 0 {
+			fmt.Printf("• Dividends valued at the current SOL price: %d\n", report.UnpricedDividends)
+		}
+	}
+	fmt.Printf("• Total Return: $%.2f\n", report.TotalReturn)
+	if report.Unpriced > 0 {
+		fmt.Printf("• Swaps without prices left out: %d\n", report.Unpriced)
+	}
+
+	if len(report.Tokens) > 0 {
+		fmt.Println("\n🎯 Per Token:")
+		for _, t := range report.Tokens {
+			name := t.Symbol
+			if name == "" {
+				name = t.Mint[:8] + "..."
+			}
+			line := fmt.Sprintf("  • %s: %.6f held | avg $%.6f | price $%.6f | unrealized $%.2f | realized $%.2f",
+				name, t.Amount, t.AverageCost, t.Price, t.Unrealized, t.Realized)
+			if t.Dividends > 0 {
+				line += fmt.Sprintf(" | dividends $%.2f (%.2f%% on total cost)", t.Dividends, t.DividendYield)
+			}
+			if t.Untracked > 0 {
+				line += fmt.Sprintf(" | %.6f sold untracked", t.Untracked)
+			}
+			fmt.Println(line)
+		}
+	}
+
+	fmt.Println()
+	return nil
+}
@@ -0,0 +1,381 @@
+package bot
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/magooney-loon/token-2022-refill-bot/pkg/wallet"
+)
+
+const (
+	testCash  = "So11111111111111111111111111111111111111112"
+	testToken = "TokenA"
+	testOther = "TokenB"
+)
+
+func isTestCash(mint string) bool {
+	return mint == testCash
+}
+
+// swap builds a confirmed journal entry
+func swap(inputMint string, inputAmount, inputPrice float64, outputMint string, outputAmount, outputPrice float64) JournalEntry {
+	return JournalEntry{
+		Status:         "confirmed",
+		Signature:      "sig",
+		InputMint:      inputMint,
+		OutputMint:     outputMint,
+		InputAmount:    inputAmount,
+		OutputAmount:   outputAmount,
+		InputPriceUSD:  inputPrice,
+		OutputPriceUSD: outputPrice,
+	}
+}
+
+func approxEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestComputePnL(t *testing.T) {
+	type wantToken struct {
+		amount, costBasis, realized, untracked, totalCost float64
+	}
+
+	withFee := swap(testCash, 10, 10, testToken, 100, 0)
+	withFee.Fee = 0.01
+	withFee.SOLPriceUSD = 10
+
+	failed := swap(testCash, 10, 10, testToken, 100, 0)
+	failed.Status = JournalFailed
+
+	rotation := swap(testToken, 100, 2, testOther, 50, 0)
+	rotation.Fee = 0.01
+	rotation.SOLPriceUSD = 10
+
+	withFeeSell := swap(testToken, 100, 2, testCash, 20, 0)
+	withFeeSell.Fee = 0.01
+	withFeeSell.SOLPriceUSD = 10
+
+	unsent := swap(testCash, 10, 10, testToken, 100, 0)
+	unsent.Signature = ""
+
+	// Two buys at $1.001 and $2 a token, then half the first lot sold at $2
+	buysThenSell := []JournalEntry{
+		withFee,
+		swap(testCash, 20, 10, testToken, 100, 0),
+		swap(testToken, 50, 2, testCash, 10, 0),
+	}
+
+	tests := []struct {
+		name         string
+		entries      []JournalEntry
+		method       string
+		prices       map[string]float64
+		want         map[string]wantToken
+		wantUnpriced int
+		wantErr      bool
+	}{
+		{
+			name:    "average cost",
+			entries: buysThenSell,
+			method:  PnLAverageCost,
+			want: map[string]wantToken{
+				testToken: {amount: 150, costBasis: 225.075, realized: 24.975, totalCost: 300.1},
+			},
+		},
+		{
+			name:    "fifo sells the oldest lot first",
+			entries: buysThenSell,
+			method:  PnLFIFO,
+			want: map[string]wantToken{
+				testToken: {amount: 150, costBasis: 250.05, realized: 49.95, totalCost: 300.1},
+			},
+		},
+		{
+			name: "fifo across lots",
+			entries: []JournalEntry{
+				swap(testCash, 10, 10, testToken, 100, 0),
+				swap(testCash, 20, 10, testToken, 100, 0),
+				swap(testToken, 150, 3, testCash, 45, 0),
+			},
+			method: PnLFIFO,
+			want: map[string]wantToken{
+				testToken: {amount: 50, costBasis: 100, realized: 250, totalCost: 300},
+			},
+		},
+		{
+			name: "priced by the output when the input has no price",
+			entries: []JournalEntry{
+				swap(testCash, 10, 0, testToken, 100, 1.5),
+			},
+			method: PnLAverageCost,
+			want: map[string]wantToken{
+				testToken: {amount: 100, costBasis: 150, totalCost: 150},
+			},
+		},
+		{
+			name: "swaps between tracked tokens sell one and buy the other",
+			entries: []JournalEntry{
+				swap(testCash, 10, 10, testToken, 100, 0),
+				swap(testToken, 100, 2, testOther, 50, 0),
+			},
+			method: PnLAverageCost,
+			want: map[string]wantToken{
+				testToken: {realized: 100, totalCost: 100},
+				testOther: {amount: 50, costBasis: 200, totalCost: 200},
+			},
+		},
+		{
+			name: "swaps between tracked tokens count the fee once",
+			entries: []JournalEntry{
+				swap(testCash, 10, 10, testToken, 100, 0),
+				rotation,
+			},
+			method: PnLFIFO,
+			want: map[string]wantToken{
+				testToken: {realized: 100, totalCost: 100},
+				testOther: {amount: 50, costBasis: 200.1, totalCost: 200.1},
+			},
+		},
+		{
+			name: "selling for cash takes the fee from the proceeds",
+			entries: []JournalEntry{
+				swap(testCash, 10, 10, testToken, 100, 0),
+				withFeeSell,
+			},
+			method: PnLAverageCost,
+			want: map[string]wantToken{
+				testToken: {realized: 99.9, totalCost: 100},
+			},
+		},
+		{
+			name: "selling more than was bought is untracked",
+			entries: []JournalEntry{
+				swap(testCash, 10, 10, testToken, 100, 0),
+				swap(testToken, 200, 2, testCash, 40, 0),
+			},
+			method: PnLFIFO,
+			want: map[string]wantToken{
+				testToken: {realized: 100, untracked: 100, totalCost: 100},
+			},
+		},
+		{
+			name: "selling a token never bought",
+			entries: []JournalEntry{
+				swap(testOther, 10, 1, testCash, 1, 0),
+			},
+			method: PnLAverageCost,
+			want: map[string]wantToken{
+				testOther: {untracked: 10},
+			},
+		},
+		{
+			name: "failed, unsent and unpriced swaps are skipped",
+			entries: []JournalEntry{
+				failed,
+				unsent,
+				swap(testCash, 10, 0, testToken, 100, 0),
+			},
+			method:       PnLAverageCost,
+			want:         map[string]wantToken{},
+			wantUnpriced: 1,
+		},
+		{
+			name:    "unknown method",
+			method:  "lifo",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := ComputePnL(tt.entries, isTestCash, tt.method, tt.prices)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ComputePnL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if report.Unpriced != tt.wantUnpriced {
+				t.Errorf("Unpriced = %d, want %d", report.Unpriced, tt.wantUnpriced)
+			}
+			if len(report.Tokens) != len(tt.want) {
+				t.Fatalf("tracked %d tokens, want %d", len(report.Tokens), len(tt.want))
+			}
+
+			var totalCost, realized float64
+			for _, token := range report.Tokens {
+				want, ok := tt.want[token.Mint]
+				if !ok {
+					t.Errorf("unexpected token %s", token.Mint)
+					continue
+				}
+				got := wantToken{token.Amount, token.CostBasis, token.Realized, token.Untracked, token.TotalCost}
+				if !approxEqual(got.amount, want.amount) || !approxEqual(got.costBasis, want.costBasis) ||
+					!approxEqual(got.realized, want.realized) || !approxEqual(got.untracked, want.untracked) ||
+					!approxEqual(got.totalCost, want.totalCost) {
+					t.Errorf("%s = %+v, want %+v", token.Mint, got, want)
+				}
+				totalCost += token.TotalCost
+				realized += token.Realized
+			}
+			if !approxEqual(report.TotalCost, totalCost) || !approxEqual(report.Realized, realized) {
+				t.Errorf("report totals = cost %v realized %v, want %v and %v", report.TotalCost, report.Realized, totalCost, realized)
+			}
+		})
+	}
+}
+
+func TestComputePnLMarketValue(t *testing.T) {
+	entries := []JournalEntry{
+		swap(testCash, 10, 10, testToken, 100, 0),
+		swap(testCash, 5, 10, testOther, 10, 0),
+	}
+	prices := map[string]float64{testToken: 1.5}
+
+	report, err := ComputePnL(entries, isTestCash, PnLAverageCost, prices)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		mint                                       string
+		averageCost, marketValue, unrealizedReturn float64
+	}{
+		{testToken, 1, 150, 50},
+		{testOther, 5, 0, -50}, // No current price
+	}
+	for _, tt := range tests {
+		t.Run(tt.mint, func(t *testing.T) {
+			for _, token := range report.Tokens {
+				if token.Mint != tt.mint {
+					continue
+				}
+				if !approxEqual(token.AverageCost, tt.averageCost) || !approxEqual(token.MarketValue, tt.marketValue) ||
+					!approxEqual(token.Unrealized, tt.unrealizedReturn) {
+					t.Errorf("average %v, value %v, unrealized %v, want %v, %v, %v", token.AverageCost, token.MarketValue,
+						token.Unrealized, tt.averageCost, tt.marketValue, tt.unrealizedReturn)
+				}
+				return
+			}
+			t.Errorf("token %s not in the report", tt.mint)
+		})
+	}
+
+	if report.Tokens[0].Mint != testToken {
+		t.Errorf("tokens are not sorted by cost basis: first is %s", report.Tokens[0].Mint)
+	}
+	if !approxEqual(report.TotalReturn, 0) {
+		t.Errorf("TotalReturn = %v, want 0", report.TotalReturn)
+	}
+}
+
+func TestCreditDividends(t *testing.T) {
+	tests := []struct {
+		name           string
+		report         PnLReport
+		valueUSD       float64
+		wantTokenYield float64
+		wantYield      float64
+		wantReturn     float64
+	}{
+		{
+			name: "yield on total cost",
+			report: PnLReport{
+				TotalCost:  600,
+				Unrealized: 10,
+				Realized:   5,
+				Tokens: []*PnLToken{
+					{Mint: testToken, CostBasis: 150, TotalCost: 300}, // Half sold
+					{Mint: testOther, CostBasis: 300, TotalCost: 300},
+				},
+			},
+			valueUSD:       30,
+			wantTokenYield: 10,
+			wantYield:      5,
+			wantReturn:     45,
+		},
+		{
+			name: "nothing bought",
+			report: PnLReport{
+				Tokens: []*PnLToken{{Mint: testToken}},
+			},
+			valueUSD:   30,
+			wantReturn: 30,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := tt.report
+			report.creditDividends(testToken, 2, tt.valueUSD)
+
+			if report.DividendsSOL != 2 || report.Dividends != tt.valueUSD {
+				t.Errorf("dividends = %v SOL, $%v, want 2 SOL, $%v", report.DividendsSOL, report.Dividends, tt.valueUSD)
+			}
+			for _, token := range report.Tokens {
+				wantDividends, wantYield := 0.0, 0.0
+				if token.Mint == testToken {
+					wantDividends, wantYield = tt.valueUSD, tt.wantTokenYield
+				}
+				if !approxEqual(token.Dividends, wantDividends) || !approxEqual(token.DividendYield, wantYield) {
+					t.Errorf("%s dividends = $%v at %v%%, want $%v at %v%%", token.Mint, token.Dividends, token.DividendYield,
+						wantDividends, wantYield)
+				}
+			}
+			if !approxEqual(report.DividendYield, tt.wantYield) {
+				t.Errorf("DividendYield = %v, want %v", report.DividendYield, tt.wantYield)
+			}
+			if !approxEqual(report.TotalReturn, tt.wantReturn) {
+				t.Errorf("TotalReturn = %v, want %v", report.TotalReturn, tt.wantReturn)
+			}
+		})
+	}
+}
+
+func TestValueDividends(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	priced := func(hours int, solPrice float64) JournalEntry {
+		return JournalEntry{Time: start.Add(time.Duration(hours) * time.Hour), SOLPriceUSD: solPrice}
+	}
+	payout := func(hours int, amount float64) wallet.DividendTransfer {
+		return wallet.DividendTransfer{Timestamp: start.Add(time.Duration(hours) * time.Hour), Amount: amount}
+	}
+	entries := []JournalEntry{
+		priced(0, 100),
+		{Time: start.Add(9 * time.Hour)}, // No price recorded
+		priced(12, 120),
+	}
+
+	tests := []struct {
+		name         string
+		transfers    []wallet.DividendTransfer
+		wantSOL      float64
+		wantUSD      float64
+		wantUnpriced int
+	}{
+		{"none", nil, 0, 0, 0},
+		{"at a journaled price", []wallet.DividendTransfer{payout(0, 1)}, 1, 100, 0},
+		{"closest journaled price", []wallet.DividendTransfer{payout(8, 1)}, 1, 120, 0},
+		{"within the window", []wallet.DividendTransfer{payout(-24, 1)}, 1, 100, 0},
+		{"outside the window uses the current price", []wallet.DividendTransfer{payout(40, 2)}, 2, 300, 1},
+		{
+			name:         "mixed",
+			transfers:    []wallet.DividendTransfer{payout(1, 0.5), payout(13, 0.25), payout(-48, 1)},
+			wantSOL:      1.75,
+			wantUSD:      50 + 30 + 150,
+			wantUnpriced: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			amountSOL, valueUSD, unpriced := valueDividends(tt.transfers, entries, 150)
+			if !approxEqual(amountSOL, tt.wantSOL) || !approxEqual(valueUSD, tt.wantUSD) || unpriced != tt.wantUnpriced {
+				t.Errorf("valueDividends() = %v SOL, $%v, %d unpriced, want %v SOL, $%v, %d unpriced",
+					amountSOL, valueUSD, unpriced, tt.wantSOL, tt.wantUSD, tt.wantUnpriced)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/magooney-loon/token-2022-refill-bot/internal/config"
+	"github.com/magooney-loon/token-2022-refill-bot/internal/utils"
+	"github.com/magooney-loon/token-2022-refill-bot/pkg/token2022"
+	"github.com/magooney-loon/token-2022-refill-bot/pkg/wallet"
+)
+
+// Rebalance destinations
+const (
+	SellIntoSOL         = "sol"
+	SellIntoUnderweight = "underweight"
+)
+
+// RebalanceTrade is a sell of an overweight target planned by the rebalancer
+type RebalanceTrade struct {
+	InputMint  string
+	OutputMint string
+	Amount     float64 // Input token amount to sell
+	Balance    float64 // Input token held before the sell
+	Value      float64 // USD value of the sell
+	Drift      float64 // Percentage points above the target weight
+	FeeCost    float64 // USD lost to Token-2022 transfer fees
+}
+
+// Rebalancer sells targets that drifted above their weight back to the input token
+// or into the most underweight target
+type Rebalancer struct {
+	config      *config.Config
+	tokenClient *token2022.Client
+	allocator   *Allocator
+}
+
+// NewRebalancer creates a new rebalancer sharing the allocator's view of the holdings
+func NewRebalancer(cfg *config.Config, tokenClient *token2022.Client, allocator *Allocator) *Rebalancer {
+	return &Rebalancer{
+		config:      cfg,
+		tokenClient: tokenClient,
+		allocator:   allocator,
+	}
+}
+
+// Plan returns the sells needed to bring every target back within the drift band.
+// Sells whose transfer fees would eat more than the configured share of the trade are skipped.
+func (r *Rebalancer) Plan(ctx context.Context) ([]RebalanceTrade, error) {
+	settings := r.config.Trading.Rebalance
+	targets := r.config.Token.Targets
+
+	holdings, err := r.allocator.holdings(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	mints := []string{r.config.Token.InputMint}
+	for _, target := range targets {
+		mints = append(mints, target.Mint)
+	}
+	prices, err := wallet.GetTokenPrices(ctx, r.config, mints)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get token prices: %w", err)
+	}
+
+	// Only the targets make up the portfolio being balanced
+	balances := make([]wallet.TokenBalance, 0, len(targets))
+	totalWeight := 0.0
+	for _, target := range targets {
+		balances = append(balances, wallet.TokenBalance{Mint: target.Mint, Balance: holdings[target.Mint]})
+		totalWeight += target.Weight
+	}
+	portfolio := wallet.CalculatePortfolio(balances, prices)
+	if portfolio.TotalUSDValue <= 0 {
+		return nil, nil
+	}
+
+	// Drift of each target from its weight, in percentage points
+	drift := make(map[string]float64)
+	for _, token := range portfolio.Tokens {
+		for _, target := range targets {
+			if target.Mint == token.Mint {
+				drift[token.Mint] = token.Distribution - target.Weight/totalWeight*100
+			}
+		}
+	}
+
+	var trades []RebalanceTrade
+	for _, token := range portfolio.Tokens {
+		if drift[token.Mint] <= settings.DriftBandPercent {
+			continue
+		}
+
+		price := prices[token.Mint]
+		value := drift[token.Mint] / 100 * portfolio.TotalUSDValue
+		if price <= 0 || value < settings.MinTradeUSD {
+			utils.Debug("Rebalance sell below minimum trade",
+				"mint", token.Mint,
+				"value", fmt.Sprintf("$%.2f", value))
+			continue
+		}
+
+		trade := RebalanceTrade{
+			InputMint:  token.Mint,
+			OutputMint: r.destination(token.Mint, drift),
+			Amount:     value / price,
+			Balance:    token.Balance,
+			Value:      value,
+			Drift:      drift[token.Mint],
+		}
+
+		trade.FeeCost, err = r.transferFeeCost(ctx, trade, prices)
+		if err != nil {
+			return nil, err
+		}
+		if trade.FeeCost/trade.Value*100 > settings.MaxFeePercent {
+			utils.Info("Skipping rebalance sell, transfer fees too high",
+				"mint", trade.InputMint,
+				"drift", fmt.Sprintf("%.2f%%", trade.Drift),
+				"value", fmt.Sprintf("$%.2f", trade.Value),
+				"fee_cost", fmt.Sprintf("$%.2f", trade.FeeCost),
+				"max_fee", fmt.Sprintf("%.2f%%", settings.MaxFeePercent))
+			continue
+		}
+
+		trades = append(trades, trade)
+	}
+
+	return trades, nil
+}
+
+// destination picks where an overweight target is sold into
+func (r *Rebalancer) destination(mint string, drift map[string]float64) string {
+	if r.config.Trading.Rebalance.SellInto != SellIntoUnderweight {
+		return r.config.Token.InputMint
+	}
+
+	best := ""
+	for _, target := range r.config.Token.Targets {
+		if target.Mint == mint || drift[target.Mint] >= 0 {
+			continue
+		}
+		if best == "" || drift[target.Mint] < drift[best] {
+			best = target.Mint
+		}
+	}
+	if best == "" {
+		return r.config.Token.InputMint
+	}
+	return best
+}
+
+// transferFeeCost returns the USD value a trade loses to transfer fees on the sold token
+// and, when selling into another target, on the bought token
+func (r *Rebalancer) transferFeeCost(ctx context.Context, trade RebalanceTrade, prices map[string]float64) (float64, error) {
+	inputToken, err := r.tokenClient.GetTokenInfo(ctx, trade.InputMint)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get token info for %s: %w", trade.InputMint, err)
+	}
+
+	var outputToken *token2022.TokenInfo
+	if trade.OutputMint != r.config.Token.InputMint && prices[trade.OutputMint] > 0 {
+		outputToken, err = r.tokenClient.GetTokenInfo(ctx, trade.OutputMint)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get token info for %s: %w", trade.OutputMint, err)
+		}
+	}
+
+	if inputToken.TransferFee == nil && (outputToken == nil || outputToken.TransferFee == nil) {
+		return 0, nil
+	}
+	epoch, err := r.tokenClient.CurrentEpoch(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	cost := transferFeeAmount(inputToken, trade.Amount, epoch) * prices[trade.InputMint]
+	if outputToken != nil {
+		received := (trade.Value - cost) / prices[trade.OutputMint]
+		cost += transferFeeAmount(outputToken, received, epoch) * prices[trade.OutputMint]
+	}
+
+	return cost, nil
+}
+
+// transferFeeAmount returns the transfer fee withheld when moving amount of a token at an epoch
+func transferFeeAmount(info *token2022.TokenInfo, amount float64, epoch uint64) float64 {
+	if info.TransferFee == nil || amount <= 0 {
+		return 0
+	}
+	scale := math.Pow10(info.Decimals)
+	fee := info.TransferFee.CalculateFee(uint64(math.Round(amount*scale)), epoch)
+	return float64(fee) / scale
+}
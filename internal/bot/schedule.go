@@ -0,0 +1,323 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/magooney-loon/token-2022-refill-bot/internal/config"
+	"github.com/magooney-loon/token-2022-refill-bot/internal/utils"
+)
+
+// Catch-up policies for scheduled runs missed while the bot was down
+const (
+	CatchUpSkip = "skip"
+	CatchUpOnce = "once"
+	CatchUpAll  = "all"
+)
+
+// maxCatchUpRuns limits how many missed runs the all policy replays
+const maxCatchUpRuns = 24
+
+// Schedule returns the next run time after a given time
+type Schedule interface {
+	Next(after time.Time) time.Time
+}
+
+// ParseSchedule parses a five field cron expression (minute hour day-of-month month
+// day-of-week, evaluated in UTC) or an interval in the form "@every 6h"
+func ParseSchedule(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+
+	if rest, ok := strings.CutPrefix(spec, "@every "); ok {
+		interval, err := time.ParseDuration(strings.TrimSpace(rest))
+		if err != nil {
+			return nil, fmt.Errorf("invalid interval %q: %w", rest, err)
+		}
+		if interval < time.Minute {
+			return nil, fmt.Errorf("interval must be at least one minute")
+		}
+		return intervalSchedule(interval), nil
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q must have 5 fields", spec)
+	}
+
+	bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 6}}
+	var sets [5]uint64
+	for i, field := range fields {
+		set, err := parseCronField(field, bounds[i][0], bounds[i][1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron field %q: %w", field, err)
+		}
+		sets[i] = set
+	}
+
+	return &cronSchedule{
+		minute:     sets[0],
+		hour:       sets[1],
+		dom:        sets[2],
+		month:      sets[3],
+		dow:        sets[4],
+		domStarred: strings.HasPrefix(fields[2], "*"),
+		dowStarred: strings.HasPrefix(fields[4], "*"),
+	}, nil
+}
+
+// parseCronField parses a comma separated list of values, ranges and steps into a bit set
+func parseCronField(field string, min, max int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if base, stepStr, ok := strings.Cut(part, "/"); ok {
+			var err error
+			step, err = strconv.Atoi(stepStr)
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q", stepStr)
+			}
+			part = base
+		}
+
+		low, high := min, max
+		if part != "*" {
+			lowStr, highStr, isRange := strings.Cut(part, "-")
+			var err error
+			low, err = strconv.Atoi(lowStr)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value %q", lowStr)
+			}
+			high = low
+			if isRange {
+				high, err = strconv.Atoi(highStr)
+				if err != nil {
+					return 0, fmt.Errorf("invalid value %q", highStr)
+				}
+			} else if step > 1 {
+				high = max
+			}
+		}
+
+		if low < min || high > max || low > high {
+			return 0, fmt.Errorf("value out of range %d-%d", min, max)
+		}
+		for v := low; v <= high; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+// intervalSchedule runs at a fixed interval
+type intervalSchedule time.Duration
+
+func (s intervalSchedule) Next(after time.Time) time.Time {
+	return after.Add(time.Duration(s)).Truncate(time.Second)
+}
+
+// cronSchedule runs whenever every field matches the time in UTC
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domStarred, dowStarred        bool
+}
+
+func (s *cronSchedule) Next(after time.Time) time.Time {
+	t := after.UTC().Truncate(time.Minute).Add(time.Minute)
+
+	// Give up after five years, an expression like "0 0 30 2 *" never matches
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			t = t.Truncate(time.Hour).Add(time.Hour)
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// dayMatches applies cron's rule that a restricted day-of-month and day-of-week match either
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStarred || s.dowStarred {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// Scheduler emits buy triggers on a schedule, independent of the balance threshold
+type Scheduler struct {
+	schedule    Schedule
+	amount      float64
+	catchUp     string
+	path        string
+	triggerChan chan ScheduledTrigger
+
+	LastRun time.Time `json:"last_run"`
+}
+
+// NewScheduler creates a scheduler for the monitor's schedule, loading the last run time
+// so runs missed while the bot was down can be caught up
+func NewScheduler(cfg *config.MonitorConfig, wallet string, paper bool) (*Scheduler, error) {
+	schedule, err := ParseSchedule(cfg.Schedule)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheDir := "cache"
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return nil, err
+	}
+
+	name := fmt.Sprintf("schedule_%s.json", wallet[:8])
+	if paper {
+		name = fmt.Sprintf("schedule_paper_%s.json", wallet[:8])
+	}
+
+	scheduler := &Scheduler{
+		schedule:    schedule,
+		amount:      cfg.ScheduleAmount,
+		catchUp:     cfg.CatchUp,
+		path:        filepath.Join(cacheDir, name),
+		triggerChan: make(chan ScheduledTrigger, 1),
+	}
+
+	data, err := os.ReadFile(scheduler.path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, scheduler); err != nil {
+			return nil, fmt.Errorf("failed to parse schedule state: %w", err)
+		}
+	}
+
+	return scheduler, nil
+}
+
+// GetTriggerChannel returns the channel scheduled triggers are sent on
+func (s *Scheduler) GetTriggerChannel() <-chan ScheduledTrigger {
+	return s.triggerChan
+}
+
+// Start catches up on missed runs, then emits a trigger at every scheduled time
+func (s *Scheduler) Start(ctx context.Context) error {
+	now := time.Now()
+	if s.LastRun.IsZero() {
+		// First start, nothing was missed
+		if err := s.save(now); err != nil {
+			return err
+		}
+	} else if err := s.catchUpRuns(ctx, now); err != nil {
+		return err
+	}
+
+	for {
+		next := s.schedule.Next(time.Now())
+		if next.IsZero() {
+			return fmt.Errorf("schedule never runs")
+		}
+
+		utils.Info("Next scheduled buy", "at", next.UTC().Format(time.RFC3339), "amount", s.amount)
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			utils.Info("Scheduler stopped by context")
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		if err := s.emit(ctx, next, false); err != nil {
+			return err
+		}
+	}
+}
+
+// catchUpRuns emits the runs missed since the last run according to the catch-up policy
+func (s *Scheduler) catchUpRuns(ctx context.Context, now time.Time) error {
+	var missed []time.Time
+	for t := s.schedule.Next(s.LastRun); !t.IsZero() && !t.After(now); t = s.schedule.Next(t) {
+		missed = append(missed, t)
+		if len(missed) > maxCatchUpRuns {
+			missed = missed[1:]
+		}
+	}
+	if len(missed) == 0 {
+		return nil
+	}
+
+	utils.Info("Scheduled buys missed while stopped",
+		"missed", len(missed),
+		"last_run", s.LastRun.UTC().Format(time.RFC3339),
+		"policy", s.catchUp)
+
+	switch s.catchUp {
+	case CatchUpOnce:
+		missed = missed[len(missed)-1:]
+	case CatchUpAll:
+	default:
+		return s.save(missed[len(missed)-1])
+	}
+
+	for _, at := range missed {
+		if err := s.emit(ctx, at, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// emit sends a trigger for a scheduled time and records it as run. The run is recorded
+// once handed to the bot, so a crash while buying never repeats it after a restart.
+func (s *Scheduler) emit(ctx context.Context, at time.Time, catchUp bool) error {
+	trigger := ScheduledTrigger{
+		ID:          fmt.Sprintf("schedule-%d", at.Unix()),
+		ScheduledAt: at,
+		Amount:      s.amount,
+		CatchUp:     catchUp,
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case s.triggerChan <- trigger:
+	}
+
+	if err := s.save(at); err != nil {
+		utils.Warn("Failed to save schedule state", "error", err)
+	}
+	return nil
+}
+
+// save records the last run time
+func (s *Scheduler) save(lastRun time.Time) error {
+	s.LastRun = lastRun
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write to a temp file first so a crash can't corrupt the state
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
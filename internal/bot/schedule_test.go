@@ -0,0 +1,183 @@
+package bot
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// utc builds a UTC time to the minute
+func utc(year int, month time.Month, day, hour, minute int) time.Time {
+	return time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+}
+
+func TestParseScheduleErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+	}{
+		{"empty", ""},
+		{"too few fields", "* * * *"},
+		{"too many fields", "* * * * * *"},
+		{"minute out of range", "60 * * * *"},
+		{"hour out of range", "* 24 * * *"},
+		{"day of month zero", "* * 0 * *"},
+		{"month out of range", "* * * 13 *"},
+		{"day of week out of range", "* * * * 7"},
+		{"zero step", "*/0 * * * *"},
+		{"reversed range", "5-1 * * * *"},
+		{"not a number", "a * * * *"},
+		{"interval below a minute", "@every 30s"},
+		{"invalid interval", "@every soon"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseSchedule(tt.spec); err == nil {
+				t.Errorf("ParseSchedule(%q) succeeded, want an error", tt.spec)
+			}
+		})
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	tests := []struct {
+		name  string
+		spec  string
+		after time.Time
+		want  time.Time
+	}{
+		{"daily later today", "0 14 * * *", utc(2024, 1, 1, 10, 0), utc(2024, 1, 1, 14, 0)},
+		{"daily at the run time moves to tomorrow", "0 14 * * *", utc(2024, 1, 1, 14, 0), utc(2024, 1, 2, 14, 0)},
+		{"every 15 minutes", "*/15 * * * *", utc(2024, 1, 1, 10, 7).Add(30 * time.Second), utc(2024, 1, 1, 10, 15)},
+		{"step from an offset", "5/15 * * * *", utc(2024, 1, 1, 10, 21), utc(2024, 1, 1, 10, 35)},
+		{"stepped range", "5-10/2 * * * *", utc(2024, 1, 1, 10, 6), utc(2024, 1, 1, 10, 7)},
+		{"list", "0 8,20 * * *", utc(2024, 1, 1, 9, 0), utc(2024, 1, 1, 20, 0)},
+		{"weekdays skip the weekend", "30 9 * * 1-5", utc(2024, 1, 5, 10, 0), utc(2024, 1, 8, 9, 30)},
+		{"first of the month", "0 0 1 * *", utc(2024, 1, 15, 0, 0), utc(2024, 2, 1, 0, 0)},
+		{"month restriction", "0 0 1 6 *", utc(2024, 1, 1, 0, 0), utc(2024, 6, 1, 0, 0)},
+		{"leap day", "0 0 29 2 *", utc(2024, 3, 1, 0, 0), utc(2028, 2, 29, 0, 0)},
+		{"day of month or day of week: friday", "0 12 13 * 5", utc(2024, 1, 1, 0, 0), utc(2024, 1, 5, 12, 0)},
+		{"day of month or day of week: the 13th", "0 12 13 * 5", utc(2024, 1, 12, 12, 0), utc(2024, 1, 13, 12, 0)},
+		{"evaluated in UTC", "0 14 * * *", time.Date(2024, 1, 1, 15, 0, 0, 0, time.FixedZone("UTC+2", 2*60*60)), utc(2024, 1, 1, 14, 0)},
+		{"never matches", "0 0 30 2 *", utc(2024, 1, 1, 0, 0), time.Time{}},
+		{"interval", "@every 6h", utc(2024, 1, 1, 10, 0).Add(1500 * time.Millisecond), utc(2024, 1, 1, 16, 0).Add(time.Second)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := ParseSchedule(tt.spec)
+			if err != nil {
+				t.Fatalf("ParseSchedule(%q) error = %v", tt.spec, err)
+			}
+			if got := schedule.Next(tt.after); !got.Equal(tt.want) {
+				t.Errorf("Next(%s) = %s, want %s", tt.after, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchedulerCatchUp(t *testing.T) {
+	lastRun := utc(2024, 1, 1, 0, 0)
+	hourly := utc(2024, 1, 1, 5, 30) // Runs at 1:00 to 5:00 were missed
+	daysLater := utc(2024, 1, 3, 0, 30)
+
+	tests := []struct {
+		name        string
+		policy      string
+		now         time.Time
+		wantRuns    []time.Time
+		wantLastRun time.Time
+	}{
+		{
+			name:        "skip records the latest missed run",
+			policy:      CatchUpSkip,
+			now:         hourly,
+			wantLastRun: utc(2024, 1, 1, 5, 0),
+		},
+		{
+			name:        "once replays the latest missed run",
+			policy:      CatchUpOnce,
+			now:         hourly,
+			wantRuns:    []time.Time{utc(2024, 1, 1, 5, 0)},
+			wantLastRun: utc(2024, 1, 1, 5, 0),
+		},
+		{
+			name:   "all replays every missed run in order",
+			policy: CatchUpAll,
+			now:    hourly,
+			wantRuns: []time.Time{
+				utc(2024, 1, 1, 1, 0),
+				utc(2024, 1, 1, 2, 0),
+				utc(2024, 1, 1, 3, 0),
+				utc(2024, 1, 1, 4, 0),
+				utc(2024, 1, 1, 5, 0),
+			},
+			wantLastRun: utc(2024, 1, 1, 5, 0),
+		},
+		{
+			name:        "all is limited to the most recent runs",
+			policy:      CatchUpAll,
+			now:         daysLater,
+			wantRuns:    hoursBetween(utc(2024, 1, 2, 1, 0), utc(2024, 1, 3, 0, 0)),
+			wantLastRun: utc(2024, 1, 3, 0, 0),
+		},
+		{
+			name:        "nothing missed",
+			policy:      CatchUpAll,
+			now:         utc(2024, 1, 1, 0, 30),
+			wantLastRun: lastRun,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := ParseSchedule("0 * * * *")
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := &Scheduler{
+				schedule:    schedule,
+				amount:      0.5,
+				catchUp:     tt.policy,
+				path:        filepath.Join(t.TempDir(), "schedule.json"),
+				triggerChan: make(chan ScheduledTrigger, maxCatchUpRuns),
+				LastRun:     lastRun,
+			}
+
+			if err := s.catchUpRuns(context.Background(), tt.now); err != nil {
+				t.Fatalf("catchUpRuns() error = %v", err)
+			}
+			close(s.triggerChan)
+
+			var runs []time.Time
+			for trigger := range s.triggerChan {
+				if !trigger.CatchUp || trigger.Amount != 0.5 {
+					t.Errorf("trigger %s = %+v, want a catch-up of 0.5", trigger.ID, trigger)
+				}
+				runs = append(runs, trigger.ScheduledAt)
+			}
+			if len(runs) != len(tt.wantRuns) {
+				t.Fatalf("caught up %d runs %v, want %d %v", len(runs), runs, len(tt.wantRuns), tt.wantRuns)
+			}
+			for i := range runs {
+				if !runs[i].Equal(tt.wantRuns[i]) {
+					t.Errorf("run %d = %s, want %s", i, runs[i], tt.wantRuns[i])
+				}
+			}
+			if !s.LastRun.Equal(tt.wantLastRun) {
+				t.Errorf("LastRun = %s, want %s", s.LastRun, tt.wantLastRun)
+			}
+		})
+	}
+}
+
+// hoursBetween returns every full hour from start to end inclusive
+func hoursBetween(start, end time.Time) []time.Time {
+	var hours []time.Time
+	for t := start; !t.After(end); t = t.Add(time.Hour) {
+		hours = append(hours, t)
+	}
+	return hours
+}
@@ -0,0 +1,101 @@
+package bot
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/magooney-loon/token-2022-refill-bot/internal/config"
+)
+
+// Sizing strategies
+const (
+	SizingFixed   = "fixed"
+	SizingSurplus = "surplus"
+	SizingPercent = "percent"
+	SizingCapped  = "capped"
+)
+
+// SizingStrategy decides how much of the input token to swap for a given balance
+type SizingStrategy interface {
+	// Size returns the amount to swap, or 0 if nothing should be swapped
+	Size(balance, reserve float64) float64
+	// Name identifies the strategy in logs
+	Name() string
+}
+
+// NewSizingStrategy builds the sizing strategy selected in the token config.
+// A positive max_swap_amount caps the amount of every strategy.
+func NewSizingStrategy(cfg *config.TokenConfig) (SizingStrategy, error) {
+	var strategy SizingStrategy
+	switch cfg.SizingStrategy {
+	case "", SizingFixed:
+		strategy = FixedSizing{Amount: cfg.SwapAmount}
+	case SizingSurplus:
+		strategy = SurplusSizing{}
+	case SizingPercent:
+		strategy = PercentSizing{Percent: cfg.SurplusPercent}
+	case SizingCapped:
+		if cfg.MaxSwapAmount <= 0 {
+			return nil, fmt.Errorf("capped sizing requires max_swap_amount")
+		}
+		return CappedSizing{Strategy: SurplusSizing{}, Max: cfg.MaxSwapAmount}, nil
+	default:
+		return nil, fmt.Errorf("unknown sizing strategy %q", cfg.SizingStrategy)
+	}
+
+	if cfg.MaxSwapAmount > 0 {
+		strategy = CappedSizing{Strategy: strategy, Max: cfg.MaxSwapAmount}
+	}
+	return strategy, nil
+}
+
+// FixedSizing always swaps the same amount
+type FixedSizing struct {
+	Amount float64
+}
+
+func (s FixedSizing) Size(balance, reserve float64) float64 {
+	return s.Amount
+}
+
+func (s FixedSizing) Name() string {
+	return SizingFixed
+}
+
+// SurplusSizing swaps everything above the reserve
+type SurplusSizing struct{}
+
+func (s SurplusSizing) Size(balance, reserve float64) float64 {
+	return math.Max(balance-reserve, 0)
+}
+
+func (s SurplusSizing) Name() string {
+	return SizingSurplus
+}
+
+// PercentSizing swaps a percentage of the surplus above the reserve
+type PercentSizing struct {
+	Percent float64
+}
+
+func (s PercentSizing) Size(balance, reserve float64) float64 {
+	return math.Max(balance-reserve, 0) * s.Percent / 100
+}
+
+func (s PercentSizing) Name() string {
+	return fmt.Sprintf("%s(%.0f%%)", SizingPercent, s.Percent)
+}
+
+// CappedSizing limits another strategy to a maximum amount per trade
+type CappedSizing struct {
+	Strategy SizingStrategy
+	Max      float64
+}
+
+func (s CappedSizing) Size(balance, reserve float64) float64 {
+	return math.Min(s.Strategy.Size(balance, reserve), s.Max)
+}
+
+func (s CappedSizing) Name() string {
+	return fmt.Sprintf("%s(max %.4f)", s.Strategy.Name(), s.Max)
+}
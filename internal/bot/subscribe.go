@@ -0,0 +1,121 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/magooney-loon/token-2022-refill-bot/internal/utils"
+
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/gagliardetto/solana-go/rpc/ws"
+)
+
+const (
+	minReconnectDelay = time.Second
+	maxReconnectDelay = time.Minute
+)
+
+// subscribe keeps an accountSubscribe on the watched account open, reporting a balance check
+// on every balance change and reconnecting with backoff whenever the socket drops
+func (m *Monitor) subscribe(ctx context.Context) {
+	delay := minReconnectDelay
+
+	for {
+		connected, err := m.runSubscription(ctx)
+		m.socketUp.Store(false)
+
+		if ctx.Err() != nil {
+			utils.Info("Account subscription stopped by context")
+			return
+		}
+		select {
+		case <-m.stopChan:
+			return
+		default:
+		}
+
+		// A subscription that was up for a while starts over with a short delay
+		if connected {
+			delay = minReconnectDelay
+		}
+		utils.Warn("Account subscription dropped, polling until reconnected",
+			"error", err,
+			"retry_in", delay,
+			"poll_interval", m.checkInterval)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-m.stopChan:
+			return
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+	}
+}
+
+// runSubscription connects and forwards balance changes until the socket fails.
+// It reports whether the subscription was established.
+func (m *Monitor) runSubscription(ctx context.Context) (bool, error) {
+	client, err := ws.Connect(ctx, m.wsEndpoint)
+	if err != nil {
+		return false, fmt.Errorf("failed to connect websocket: %w", err)
+	}
+	defer client.Close()
+
+	sub, err := client.AccountSubscribe(m.account.Address, rpc.CommitmentConfirmed)
+	if err != nil {
+		return false, fmt.Errorf("failed to subscribe to %s: %w", m.account.Address, err)
+	}
+	defer sub.Unsubscribe()
+
+	m.socketUp.Store(true)
+	utils.Info("Subscribed to balance account",
+		"endpoint", m.wsEndpoint,
+		"account", m.account.Address.String(),
+		"mint", m.account.Mint)
+
+	// Catch any change that happened while the socket was down
+	if err := m.checkBalance(ctx); err != nil {
+		utils.Error("Balance check after subscribing failed", err)
+	}
+
+	var lastAmount uint64
+	for {
+		select {
+		case <-ctx.Done():
+			return true, ctx.Err()
+		case <-m.stopChan:
+			return true, nil
+		case err := <-sub.Err():
+			return true, err
+		case result, ok := <-sub.Response():
+			if !ok {
+				return true, fmt.Errorf("subscription closed")
+			}
+			if result == nil {
+				continue
+			}
+			amount, err := m.account.Amount(&result.Value.Account)
+			if err != nil {
+				utils.Debug("Failed to read balance from notification", "error", err)
+				continue
+			}
+			if amount == lastAmount {
+				continue
+			}
+			lastAmount = amount
+
+			utils.Debug("Watched balance changed",
+				"mint", m.account.Mint,
+				"amount", amount,
+				"slot", result.Context.Slot)
+			m.report(amount)
+		}
+	}
+}
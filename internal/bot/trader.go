@@ -2,15 +2,19 @@ package bot
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math"
 	"math/big"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/magooney-loon/token-2022-refill-bot/internal/config"
 	"github.com/magooney-loon/token-2022-refill-bot/internal/jupiter"
 	"github.com/magooney-loon/token-2022-refill-bot/internal/utils"
 	"github.com/magooney-loon/token-2022-refill-bot/pkg/token2022"
+	"github.com/magooney-loon/token-2022-refill-bot/pkg/wallet"
 
 	"github.com/gagliardetto/solana-go"
 	"github.com/gagliardetto/solana-go/rpc"
@@ -22,113 +26,633 @@ type Trader struct {
 	rpcClient     *rpc.Client
 	wallet        *solana.Wallet
 	tokenClient   *token2022.Client
+	confirmer     *Confirmer
+	broadcaster   *Broadcaster
+	guard         *swapGuard
+	ledger        *PaperLedger
+	fees          *PriorityFeeEstimator
+	sizer         SizingStrategy
+	book          *CostBasisBook
+	buyGuard      *BuyGuard
+	twap          *TWAPScheduler
+	journal       *Journal
 }
 
-func NewTrader(cfg *config.Config, jupiterClient *jupiter.Client, rpcClient *rpc.Client, wallet *solana.Wallet) *Trader {
+func NewTrader(cfg *config.Config, jupiterClient *jupiter.Client, rpcClient *rpc.Client, wallet *solana.Wallet) (*Trader, error) {
+	sizer, err := NewSizingStrategy(cfg.RefillSizing())
+	if err != nil {
+		return nil, fmt.Errorf("invalid sizing strategy: %w", err)
+	}
+
+	confirmer := NewConfirmer(
+		rpcClient,
+		rpc.CommitmentType(cfg.RPC.Commitment),
+		time.Duration(cfg.RPC.ConfirmTimeoutSeconds)*time.Second,
+	)
+
 	return &Trader{
 		config:        cfg,
 		jupiterClient: jupiterClient,
 		rpcClient:     rpcClient,
 		wallet:        wallet,
 		tokenClient:   token2022.NewClient(cfg, rpcClient),
+		confirmer:     confirmer,
+		broadcaster:   NewBroadcaster(rpcClient, confirmer, time.Duration(cfg.RPC.RebroadcastIntervalSeconds)*time.Second),
+		guard:         newSwapGuard(),
+		fees:          NewPriorityFeeEstimator(rpcClient, &cfg.Trading.PriorityFee),
+		sizer:         sizer,
+	}, nil
+}
+
+// SetPaperLedger switches the trader to dry-run mode, booking simulated swaps on the ledger
+func (t *Trader) SetPaperLedger(ledger *PaperLedger) {
+	t.ledger = ledger
+}
+
+// SetSizingStrategy replaces the strategy deciding how much to swap
+func (t *Trader) SetSizingStrategy(sizer SizingStrategy) {
+	t.sizer = sizer
+}
+
+// SetCostBasis makes the trader hold back exit proceeds locked in the book
+func (t *Trader) SetCostBasis(book *CostBasisBook) {
+	t.book = book
+}
+
+// SetBuyGuard makes the trader check buy conditions on every buy quote
+func (t *Trader) SetBuyGuard(guard *BuyGuard) {
+	t.buyGuard = guard
+}
+
+// SetTWAP makes the trader hold back the input still owed to split orders
+func (t *Trader) SetTWAP(twap *TWAPScheduler) {
+	t.twap = twap
+}
+
+// SetJournal makes the trader record every swap outcome in the trade journal
+func (t *Trader) SetJournal(journal *Journal) {
+	t.journal = journal
+}
+
+// SwapAmount returns the amount the sizing strategy would swap at the given balance of the refill mint
+func (t *Trader) SwapAmount(balance float64) float64 {
+	return t.sizer.Size(balance, t.reserve(t.config.RefillMint(), ""))
+}
+
+// Reserve returns the input token balance refills must leave untouched
+func (t *Trader) Reserve() float64 {
+	return t.reserve(t.config.Token.InputMint, "")
+}
+
+// Available returns the balance of mint a new order may spend, after the reserve and
+// the input already owed to split orders
+func (t *Trader) Available(mint string, balance float64) float64 {
+	return balance - t.reserve(mint, "")
+}
+
+// reserve returns the balance of mint swaps must leave untouched: the configured reserve,
+// exit proceeds held back from refills, and input owed to split orders other than the one
+// with triggerID. Only the input token and a watched token have a reserve.
+func (t *Trader) reserve(mint, triggerID string) float64 {
+	var reserve float64
+	switch mint {
+	case t.config.Token.InputMint:
+		reserve = t.config.Wallet.ReserveAmount
+		if t.book != nil && !t.config.Trading.Exits.RebuyProceeds {
+			reserve += t.book.Locked()
+		}
+	case t.config.Monitor.WatchMint:
+		reserve = t.config.Monitor.TokenReserveAmount
+	default:
+		return 0
 	}
+	if t.twap != nil {
+		reserve += t.twap.Reserved(mint, triggerID)
+	}
+	return reserve
 }
 
-func (t *Trader) ExecuteSwap(ctx context.Context, balance float64) error {
-	// Use configured swap amount instead of full balance
-	amount := t.config.Token.SwapAmount
+// SizingName describes the active sizing strategy
+func (t *Trader) SizingName() string {
+	return t.sizer.Name()
+}
 
-	// Ensure we have enough balance (including reserve)
-	if balance < amount+t.config.Wallet.ReserveAmount {
-		return fmt.Errorf("insufficient balance for swap: have %.6f, need %.6f (including reserve)",
-			balance, amount+t.config.Wallet.ReserveAmount)
+// checkFeeBalance fails when the SOL balance is below the floor kept for network fees,
+// using the virtual balance in dry-run mode
+func (t *Trader) checkFeeBalance(ctx context.Context) error {
+	floor := t.config.Wallet.MinFeeBalance
+	if floor <= 0 {
+		return nil
+	}
+
+	lamports, err := t.rpcClient.GetBalance(ctx, t.wallet.PublicKey(), rpc.CommitmentConfirmed)
+	if err != nil {
+		return fmt.Errorf("failed to get SOL balance: %w", err)
+	}
+	balance := float64(lamports.Value) / 1e9
+
+	if t.ledger != nil {
+		balance, err = t.ledger.Sync(NativeMint, balance)
+		if err != nil {
+			utils.Warn("Failed to save paper ledger", "error", err)
+		}
+	}
+
+	if balance < floor {
+		return fmt.Errorf("insufficient SOL for fees: have %.6f, need at least %.6f", balance, floor)
+	}
+	return nil
+}
+
+// ExecuteSwap executes a swap order. An order's trigger produces at most one confirmed swap:
+// a fresh quote is only requested once every earlier transaction for it has expired.
+func (t *Trader) ExecuteSwap(ctx context.Context, order SwapOrder) (*SwapResult, error) {
+	result, err := t.executeSwap(ctx, order)
+
+	// Skipped buys never traded, anything else that failed is journaled
+	var skipped *BuySkippedError
+	if err != nil && !errors.As(err, &skipped) {
+		t.record(ctx, JournalEntry{
+			Trigger:    order.TriggerID,
+			Reason:     order.Reason,
+			Status:     JournalFailed,
+			Error:      err.Error(),
+			InputMint:  order.InputMint,
+			OutputMint: order.OutputMint,
+			Requested:  order.Amount,
+		}, nil, nil)
+	}
+	return result, err
+}
+
+func (t *Trader) executeSwap(ctx context.Context, order SwapOrder) (*SwapResult, error) {
+	t.guard.Prune(24 * time.Hour)
+	triggerID := order.TriggerID
+
+	if result := t.guard.Result(triggerID); result != nil {
+		utils.Warn("Trigger already produced a swap, skipping",
+			"trigger", triggerID,
+			"signature", result.TxSignature)
+		return result, nil
+	}
+
+	// A swap from an earlier trigger that lands late makes this balance stale
+	if err := t.settleEarlierTriggers(ctx, triggerID); err != nil {
+		return nil, err
+	}
+
+	amount := order.Amount
+	if amount <= 0 {
+		return nil, fmt.Errorf("nothing to swap: balance %.6f does not exceed reserve %.6f",
+			order.Balance, t.reserve(order.InputMint, triggerID))
+	}
+
+	// Ensure we have enough balance, the reserve only applies to the tokens the bot spends
+	required := amount + t.reserve(order.InputMint, triggerID)
+	if order.Balance < required {
+		return nil, fmt.Errorf("insufficient balance for swap: have %.6f, need %.6f (including reserve)",
+			order.Balance, required)
+	}
+
+	// Spending another token still takes SOL for network fees
+	if order.InputMint != NativeMint {
+		if err := t.checkFeeBalance(ctx); err != nil {
+			return nil, err
+		}
 	}
 
 	// Get token info for both tokens
-	inputToken, err := t.tokenClient.GetTokenInfo(ctx, t.config.Token.InputMint)
+	inputToken, err := t.tokenClient.GetTokenInfo(ctx, order.InputMint)
 	if err != nil {
-		return fmt.Errorf("failed to get input token info: %w", err)
+		return nil, fmt.Errorf("failed to get input token info: %w", err)
 	}
 
-	outputToken, err := t.tokenClient.GetTokenInfo(ctx, t.config.Token.OutputMint)
+	outputToken, err := t.tokenClient.GetTokenInfo(ctx, order.OutputMint)
 	if err != nil {
-		return fmt.Errorf("failed to get output token info: %w", err)
+		return nil, fmt.Errorf("failed to get output token info: %w", err)
 	}
 
 	// Convert amount to lamports/smallest unit
 	rawAmount := t.toRawAmount(amount, inputToken.Decimals)
 
 	utils.Info("Starting swap execution",
+		"trigger", triggerID,
+		"dry_run", t.ledger != nil,
 		"amount", amount,
 		"raw_amount", rawAmount,
 		"input_token", inputToken.Symbol,
 		"output_token", outputToken.Symbol)
 
-	// Calculate effective slippage with tax buffer
+	// Transfer fees depend on the epoch, a staged fee change takes over at its epoch
+	var epoch uint64
+	if inputToken.TransferFee != nil || outputToken.TransferFee != nil {
+		epoch, err = t.tokenClient.CurrentEpoch(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Calculate effective slippage with tax buffer, sells of Token-2022 tokens are taxed too.
+	// The input fee is exact for the amount sent; the output amount is only known from the
+	// quote, so its fee is padded at the active rate.
 	effectiveSlippage := uint16(t.config.Token.SlippageBPS)
-	outputTransferFee := outputToken.GetTransferFeeBps()
-	if outputTransferFee > 0 {
-		effectiveSlippage += outputTransferFee
+	transferFee := feeBps(inputToken.TransferFee.CalculateFee(rawAmount, epoch), rawAmount) +
+		outputToken.GetTransferFeeBps(epoch)
+	if transferFee > 0 {
+		effectiveSlippage += transferFee
 		utils.Debug("Added tax buffer to slippage",
 			"base_slippage", t.config.Token.SlippageBPS,
-			"tax_buffer", outputTransferFee,
+			"tax_buffer", transferFee,
 			"effective_slippage", effectiveSlippage)
 	}
 
-	// Get quote with retry
-	quote, err := utils.WithRetry(func() (*jupiter.Quote, error) {
-		return t.jupiterClient.GetQuote(
-			ctx,
-			t.config.Token.InputMint,
-			t.config.Token.OutputMint,
-			rawAmount,
-			int(effectiveSlippage),
-			"",
-		)
-	}, t.config.Monitor.MaxRetries, time.Second*time.Duration(t.config.Monitor.RetryDelaySeconds))
+	retryDelay := time.Second * time.Duration(t.config.Monitor.RetryDelaySeconds)
+
+	var lastErr error
+	for attempt := 0; attempt <= t.config.Monitor.MaxRetries; attempt++ {
+		if attempt > 0 {
+			utils.Info("Retrying swap with a fresh quote",
+				"trigger", triggerID,
+				"attempt", attempt,
+				"max_retries", t.config.Monitor.MaxRetries)
+			time.Sleep(retryDelay)
+		}
+
+		// Never requote while an earlier transaction for this trigger can still land
+		result, err := t.resolveAttempts(ctx, triggerID)
+		if err != nil {
+			return nil, err
+		}
+		if result != nil {
+			return result, nil
+		}
+
+		// Get quote with retry
+		quote, err := utils.WithRetry(func() (*jupiter.Quote, error) {
+			return t.jupiterClient.GetQuote(
+				ctx,
+				order.InputMint,
+				order.OutputMint,
+				rawAmount,
+				int(effectiveSlippage),
+				"",
+			)
+		}, t.config.Monitor.MaxRetries, retryDelay)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to get quote: %w", err)
+		}
+
+		// The output token's transfer fee is withheld from what the quote delivers
+		expectedOutput, err := t.netOutput(quote, outputToken, epoch)
+		if err != nil {
+			return nil, err
+		}
+
+		// Buy conditions are checked once per trigger, retries only chase the same buy
+		if attempt == 0 && t.buyGuard != nil && t.isBuy(order) {
+			err := t.buyGuard.Check(ctx, order.InputMint, order.OutputMint,
+				t.fromRawAmount(quote.InAmount, inputToken.Decimals),
+				expectedOutput)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		// Check price impact
+		priceImpact, err := t.calculatePriceImpact(quote.PriceImpactPct)
+		if err != nil {
+			return nil, fmt.Errorf("failed to calculate price impact: %w", err)
+		}
+
+		// Check if price impact is too high
+		maxPriceImpact := float64(effectiveSlippage) / 100.0
+		if priceImpact > maxPriceImpact {
+			return nil, fmt.Errorf("price impact too high: %.2f%% (max: %.2f%%)", priceImpact*100, maxPriceImpact*100)
+		}
+
+		// Every retry escalates the priority fee
+		priorityFee := t.fees.Estimate(ctx, quote, attempt)
+
+		// Build and sign the swap; nothing is sent here so retrying is safe
+		swapTx, err := utils.WithRetry(func() (*jupiter.SwapTransaction, error) {
+			return t.jupiterClient.BuildSwapTransaction(
+				ctx,
+				t.wallet,
+				quote,
+				priorityFee,
+				t.ledger != nil,
+			)
+		}, t.config.Monitor.MaxRetries, retryDelay)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to build swap: %w", err)
+		}
+
+		current := &swapAttempt{
+			reason:         order.Reason,
+			swapTx:         swapTx,
+			quote:          quote,
+			inputToken:     inputToken,
+			outputToken:    outputToken,
+			expectedOutput: expectedOutput,
+			priceImpact:    priceImpact,
+		}
+		if t.ledger != nil {
+			return t.executePaperSwap(ctx, triggerID, current)
+		}
+
+		t.guard.AddAttempt(triggerID, current)
+		if order.OnSubmit != nil {
+			order.OnSubmit(Submission{
+				Signature:            swapTx.Signature.String(),
+				LastValidBlockHeight: swapTx.LastValidBlockHeight,
+			})
+		}
+
+		_, err = t.broadcaster.SendAndConfirm(ctx, swapTx)
+		if err == nil {
+			return t.completeAttempt(ctx, triggerID, current)
+		}
+
+		lastErr = err
+		var failed *TransactionFailedError
+		switch {
+		case errors.As(err, &failed), errors.Is(err, ErrBlockhashExpired):
+			// The transaction can never land now
+			t.guard.Settle(current)
+		}
+
+		utils.Warn("Swap did not land",
+			"trigger", triggerID,
+			"signature", swapTx.Signature.String(),
+			"error", err)
+	}
+
+	// Give any transaction still in flight the chance to land before giving up
+	result, err := t.resolveAttempts(ctx, triggerID)
+	if err != nil {
+		return nil, err
+	}
+	if result != nil {
+		return result, nil
+	}
+
+	return nil, fmt.Errorf("swap not confirmed after %d attempts: %w", t.config.Monitor.MaxRetries+1, lastErr)
+}
+
+// isBuy reports whether an order swaps the input token into a target
+func (t *Trader) isBuy(order SwapOrder) bool {
+	return order.InputMint == t.config.Token.InputMint && t.config.Token.IsTarget(order.OutputMint)
+}
+
+// resolveAttempts waits for the outcome of every unsettled transaction sent for a trigger.
+// It returns the swap result if one of them landed.
+func (t *Trader) resolveAttempts(ctx context.Context, triggerID string) (*SwapResult, error) {
+	for _, attempt := range t.guard.Unsettled(triggerID) {
+		sig := attempt.swapTx.Signature
+
+		_, err := t.confirmer.WaitForConfirmation(ctx, sig, attempt.swapTx.LastValidBlockHeight)
+		if err == nil {
+			utils.Info("Earlier swap transaction landed", "trigger", triggerID, "signature", sig.String())
+			return t.completeAttempt(ctx, triggerID, attempt)
+		}
+
+		var failed *TransactionFailedError
+		if errors.As(err, &failed) || errors.Is(err, ErrBlockhashExpired) {
+			t.guard.Settle(attempt)
+			continue
+		}
+
+		return nil, fmt.Errorf("outcome of swap %s unknown, refusing to send another: %w", sig, err)
+	}
+	return nil, nil
+}
+
+// settleEarlierTriggers resolves transactions still in flight from other triggers
+func (t *Trader) settleEarlierTriggers(ctx context.Context, triggerID string) error {
+	for _, id := range t.guard.PendingTriggers(triggerID) {
+		result, err := t.resolveAttempts(ctx, id)
+		if err != nil {
+			return err
+		}
+		if result != nil {
+			return fmt.Errorf("swap for earlier trigger %s landed late (%s), skipping trigger %s",
+				id, result.TxSignature, triggerID)
+		}
+	}
+	return nil
+}
+
+// completeAttempt reads the landed transaction and records it as the trigger's swap
+func (t *Trader) completeAttempt(ctx context.Context, triggerID string, attempt *swapAttempt) (*SwapResult, error) {
+	sig := attempt.swapTx.Signature
+
+	// Record the outcome before reading it so a fetch failure can't lead to a second swap
+	t.guard.Complete(triggerID, &SwapResult{
+		InputMint:   attempt.quote.InputMint,
+		OutputMint:  attempt.quote.OutputMint,
+		Timestamp:   time.Now(),
+		TxSignature: sig.String(),
+	})
 
+	tx, err := t.confirmer.FetchTransaction(ctx, sig)
 	if err != nil {
-		return fmt.Errorf("failed to get quote: %w", err)
+		return nil, fmt.Errorf("failed to fetch confirmed swap %s: %w", sig, err)
 	}
 
-	// Check price impact
-	priceImpact, err := t.calculatePriceImpact(quote.PriceImpactPct)
+	result := t.buildSwapResult(tx, attempt)
+	t.guard.Complete(triggerID, result)
+	t.record(ctx, JournalEntry{Trigger: triggerID, Reason: attempt.reason}, attempt, result)
+
+	utils.Info("Swap confirmed",
+		"trigger", triggerID,
+		"signature", result.TxSignature,
+		"status", result.Status,
+		"input_amount", fmt.Sprintf("%.6f %s", result.InputAmount, attempt.inputToken.Symbol),
+		"output_amount", fmt.Sprintf("%.6f %s", result.OutputAmount, attempt.outputToken.Symbol),
+		"quoted_output", fmt.Sprintf("%.6f %s", result.QuotedOutput, attempt.outputToken.Symbol),
+		"fee", fmt.Sprintf("%.9f SOL", result.Fee),
+		"priority_fee", fmt.Sprintf("%.9f SOL", result.PriorityFee),
+		"route", result.Route,
+		"price_impact", fmt.Sprintf("%.2f%%", result.PriceImpact*100))
+
+	return result, nil
+}
+
+// RecoverSubmission looks up a swap sent before a restart. It returns the swap result if the
+// transaction landed, nil if it can never land, or an error if its outcome is still unknown.
+func (t *Trader) RecoverSubmission(ctx context.Context, sub Submission, inputMint, outputMint string) (*SwapResult, error) {
+	sig, err := solana.SignatureFromBase58(sub.Signature)
 	if err != nil {
-		return fmt.Errorf("failed to calculate price impact: %w", err)
+		return nil, fmt.Errorf("invalid signature %s: %w", sub.Signature, err)
 	}
 
-	// Check if price impact is too high
-	maxPriceImpact := float64(effectiveSlippage) / 100.0
-	if priceImpact > maxPriceImpact {
-		return fmt.Errorf("price impact too high: %.2f%% (max: %.2f%%)", priceImpact*100, maxPriceImpact*100)
+	_, err = t.confirmer.WaitForConfirmation(ctx, sig, sub.LastValidBlockHeight)
+	var failed *TransactionFailedError
+	switch {
+	case errors.As(err, &failed), errors.Is(err, ErrBlockhashExpired):
+		return nil, nil
+	case err != nil:
+		return nil, err
 	}
 
-	// Execute swap with retry
-	sig, err := utils.WithRetry(func() (solana.Signature, error) {
-		return t.jupiterClient.ExecuteSwap(
-			ctx,
-			t.wallet,
-			quote,
-			t.rpcClient,
-			36699, // TODO: Add priority fee config
-		)
-	}, t.config.Monitor.MaxRetries, time.Second*time.Duration(t.config.Monitor.RetryDelaySeconds))
+	tx, err := t.confirmer.FetchTransaction(ctx, sig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch confirmed swap %s: %w", sig, err)
+	}
 
+	inputToken, err := t.tokenClient.GetTokenInfo(ctx, inputMint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get input token info: %w", err)
+	}
+	outputToken, err := t.tokenClient.GetTokenInfo(ctx, outputMint)
 	if err != nil {
-		return fmt.Errorf("failed to execute swap: %w", err)
+		return nil, fmt.Errorf("failed to get output token info: %w", err)
 	}
 
-	// Convert amounts to human readable
-	inAmount := t.fromRawAmount(quote.InAmount, inputToken.Decimals)
-	outAmount := t.fromRawAmount(quote.OutAmount, outputToken.Decimals)
+	signatures := 1
+	if parsed, err := tx.Transaction.GetTransaction(); err == nil {
+		signatures = len(parsed.Signatures)
+	}
 
-	utils.Info("Swap executed successfully",
-		"signature", sig.String(),
-		"input_amount", fmt.Sprintf("%.6f %s", inAmount, inputToken.Symbol),
-		"output_amount", fmt.Sprintf("%.6f %s", outAmount, outputToken.Symbol),
-		"price_impact", fmt.Sprintf("%.2f%%", priceImpact*100))
+	result := t.transactionResult(tx, sig, inputToken, outputToken, inputMint, outputMint)
+	result.PriorityFee = float64(priorityFeeLamports(tx.Meta.Fee, signatures)) / float64(solana.LAMPORTS_PER_SOL)
 
-	return nil
+	t.record(ctx, JournalEntry{
+		Reason:       TradeReasonTWAP,
+		Status:       JournalRecovered,
+		InputSymbol:  inputToken.Symbol,
+		OutputSymbol: outputToken.Symbol,
+	}, nil, result)
+	return result, nil
+}
+
+// record appends a swap outcome to the trade journal, filling in the quote the attempt was
+// built from, the result and current USD prices. Journal failures never fail the swap.
+func (t *Trader) record(ctx context.Context, entry JournalEntry, attempt *swapAttempt, result *SwapResult) {
+	if t.journal == nil {
+		return
+	}
+
+	entry.Time = time.Now()
+	entry.DryRun = t.ledger != nil
+
+	if attempt != nil {
+		quote := attempt.quote
+		entry.Quote = quote
+		entry.InputSymbol = attempt.inputToken.Symbol
+		entry.OutputSymbol = attempt.outputToken.Symbol
+		entry.QuotedInput = t.fromRawAmount(quote.InAmount, attempt.inputToken.Decimals)
+		entry.QuotedOutput = attempt.expectedOutput
+		entry.MinimumOutput = t.fromRawAmount(quote.OtherAmountThreshold, attempt.outputToken.Decimals)
+		entry.Route = routeLabel(quote)
+		entry.PriceImpact = attempt.priceImpact
+	}
+
+	if result != nil {
+		entry.Time = result.Timestamp
+		if entry.Status == "" {
+			entry.Status = result.Status
+		}
+		entry.InputMint = result.InputMint
+		entry.OutputMint = result.OutputMint
+		entry.Signature = result.TxSignature
+		entry.Slot = result.Slot
+		entry.InputAmount = result.InputAmount
+		entry.OutputAmount = result.OutputAmount
+		entry.Fee = result.Fee
+		entry.PriorityFee = result.PriorityFee
+		if result.Route != "" {
+			entry.Route = result.Route
+		}
+	}
+
+	prices, err := wallet.GetTokenPrices(ctx, t.config, []string{entry.InputMint, entry.OutputMint, NativeMint})
+	if err != nil {
+		utils.Debug("Failed to price journal entry", "signature", entry.Signature, "error", err)
+	} else {
+		entry.InputPriceUSD = prices[entry.InputMint]
+		entry.OutputPriceUSD = prices[entry.OutputMint]
+		entry.SOLPriceUSD = prices[NativeMint]
+	}
+
+	if err := t.journal.Append(entry); err != nil {
+		utils.Warn("Failed to write trade journal", "path", t.journal.Path(), "signature", entry.Signature, "error", err)
+	}
+}
+
+// buildSwapResult fills a SwapResult from the confirmed transaction's balance changes
+func (t *Trader) buildSwapResult(tx *rpc.GetTransactionResult, attempt *swapAttempt) *SwapResult {
+	quote := attempt.quote
+	signatures := int(attempt.swapTx.Transaction.Message.Header.NumRequiredSignatures)
+
+	result := t.transactionResult(tx, attempt.swapTx.Signature, attempt.inputToken, attempt.outputToken, quote.InputMint, quote.OutputMint)
+	result.QuotedOutput = attempt.expectedOutput
+	result.PriorityFee = float64(priorityFeeLamports(tx.Meta.Fee, signatures)) / float64(solana.LAMPORTS_PER_SOL)
+	result.Route = routeLabel(quote)
+	result.PriceImpact = attempt.priceImpact
+	return result
+}
+
+// transactionResult reads the wallet's side of a confirmed swap from its balance changes
+func (t *Trader) transactionResult(tx *rpc.GetTransactionResult, sig solana.Signature, inputToken, outputToken *token2022.TokenInfo, inputMint, outputMint string) *SwapResult {
+	owner := t.wallet.PublicKey()
+	inDelta := tokenDelta(tx, owner, inputMint)
+	outDelta := tokenDelta(tx, owner, outputMint)
+
+	timestamp := time.Now()
+	if tx.BlockTime != nil {
+		timestamp = tx.BlockTime.Time()
+	}
+
+	return &SwapResult{
+		InputMint:    inputMint,
+		OutputMint:   outputMint,
+		InputAmount:  t.fromRawAmount(new(big.Int).Neg(inDelta).String(), inputToken.Decimals),
+		OutputAmount: t.fromRawAmount(outDelta.String(), outputToken.Decimals),
+		Fee:          float64(tx.Meta.Fee) / float64(solana.LAMPORTS_PER_SOL),
+		Timestamp:    timestamp,
+		TxSignature:  sig.String(),
+		Slot:         tx.Slot,
+		Status:       t.config.RPC.Commitment,
+	}
+}
+
+// priorityFeeLamports returns the part of a transaction fee above the per-signature base fee
+func priorityFeeLamports(totalFee uint64, signatures int) uint64 {
+	baseFee := baseFeeLamports * uint64(signatures)
+	if totalFee <= baseFee {
+		return 0
+	}
+	return totalFee - baseFee
+}
+
+// netOutput returns the quoted output left after the output token's transfer fee, in human units
+func (t *Trader) netOutput(quote *jupiter.Quote, outputToken *token2022.TokenInfo, epoch uint64) (float64, error) {
+	outAmount, err := strconv.ParseUint(quote.OutAmount, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid quote output amount %q: %w", quote.OutAmount, err)
+	}
+	fee := outputToken.TransferFee.CalculateFee(outAmount, epoch)
+	return t.fromRawAmount(strconv.FormatUint(outAmount-fee, 10), outputToken.Decimals), nil
+}
+
+// feeBps returns a raw fee as basis points of a raw amount, rounded up
+func feeBps(fee, amount uint64) uint16 {
+	if fee == 0 || amount == 0 {
+		return 0
+	}
+	return uint16(math.Ceil(float64(fee) / float64(amount) * 10000))
+}
+
+// routeLabel joins the AMM labels of a quote's route plan
+func routeLabel(quote *jupiter.Quote) string {
+	labels := make([]string, 0, len(quote.RoutePlan))
+	for _, route := range quote.RoutePlan {
+		labels = append(labels, route.SwapInfo.Label)
+	}
+	return strings.Join(labels, " -> ")
 }
 
 func (t *Trader) calculatePriceImpact(priceImpactStr string) (float64, error) {
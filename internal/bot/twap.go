@@ -0,0 +1,325 @@
+package bot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/magooney-loon/token-2022-refill-bot/internal/config"
+	"github.com/magooney-loon/token-2022-refill-bot/internal/utils"
+)
+
+// TWAP child states
+const (
+	ChildPending   = "pending"
+	ChildSubmitted = "submitted"
+	ChildDone      = "done"
+	ChildFailed    = "failed"
+)
+
+const (
+	// twapPollInterval is how often the bot looks for split order slices that are due
+	twapPollInterval = 15 * time.Second
+
+	// maxChildAttempts is how many ticks a slice is retried before its amount is released
+	maxChildAttempts = 3
+)
+
+// TWAPChild is one slice of a split order
+type TWAPChild struct {
+	Index        int          `json:"index"`
+	Amount       float64      `json:"amount"`
+	DueAt        time.Time    `json:"due_at"`
+	Status       string       `json:"status"`
+	Attempts     int          `json:"attempts"`
+	Submissions  []Submission `json:"submissions,omitempty"`
+	InputAmount  float64      `json:"input_amount,omitempty"`
+	OutputAmount float64      `json:"output_amount,omitempty"`
+	Signature    string       `json:"signature,omitempty"`
+	Error        string       `json:"error,omitempty"`
+}
+
+// TWAPOrder is a large swap split into slices spread over a time window
+type TWAPOrder struct {
+	ID         string      `json:"id"`
+	InputMint  string      `json:"input_mint"`
+	OutputMint string      `json:"output_mint"`
+	Amount     float64     `json:"amount"`
+	Children   []TWAPChild `json:"children"`
+	CreatedAt  time.Time   `json:"created_at"`
+}
+
+// ChildTriggerID identifies a slice to the trader's swap guard
+func (o *TWAPOrder) ChildTriggerID(index int) string {
+	return fmt.Sprintf("%s#%d", o.ID, index)
+}
+
+// Finished reports whether every slice is done or failed
+func (o *TWAPOrder) Finished() bool {
+	for _, child := range o.Children {
+		if child.Status != ChildDone && child.Status != ChildFailed {
+			return false
+		}
+	}
+	return true
+}
+
+// DueChild is a slice ready to be executed
+type DueChild struct {
+	Order *TWAPOrder
+	Child TWAPChild
+}
+
+// TWAPScheduler splits large swaps into slices and persists their progress,
+// so a restart resumes an order instead of repeating it
+type TWAPScheduler struct {
+	mu     sync.Mutex
+	path   string
+	config *config.TWAPConfig
+
+	Orders    map[string]*TWAPOrder `json:"orders"`
+	UpdatedAt time.Time             `json:"updated_at"`
+}
+
+// LoadTWAPScheduler loads the wallet's split orders, paper trades are kept in their own file
+func LoadTWAPScheduler(cfg *config.TWAPConfig, wallet string, paper bool) (*TWAPScheduler, error) {
+	cacheDir := "cache"
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return nil, err
+	}
+
+	name := fmt.Sprintf("twap_%s.json", wallet[:8])
+	if paper {
+		name = fmt.Sprintf("twap_paper_%s.json", wallet[:8])
+	}
+
+	scheduler := &TWAPScheduler{
+		path:   filepath.Join(cacheDir, name),
+		config: cfg,
+		Orders: make(map[string]*TWAPOrder),
+	}
+
+	data, err := os.ReadFile(scheduler.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return scheduler, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, scheduler); err != nil {
+		return nil, fmt.Errorf("failed to parse split orders: %w", err)
+	}
+	if scheduler.Orders == nil {
+		scheduler.Orders = make(map[string]*TWAPOrder)
+	}
+
+	if len(scheduler.Orders) > 0 {
+		utils.Info("Resuming split orders", "path", scheduler.path, "orders", len(scheduler.Orders))
+	}
+
+	return scheduler, nil
+}
+
+// ShouldSplit reports whether a swap of amount is large enough to be split
+func (s *TWAPScheduler) ShouldSplit(amount float64) bool {
+	return s.config.Slices > 1 && amount >= s.config.MinAmount
+}
+
+// Scheduled reports whether an order with id is still open
+func (s *TWAPScheduler) Scheduled(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.Orders[id]
+	return ok
+}
+
+// Schedule splits a swap into equal slices spread over the configured window.
+// Scheduling an order ID twice returns the existing order.
+func (s *TWAPScheduler) Schedule(id, inputMint, outputMint string, amount float64) (*TWAPOrder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if order, ok := s.Orders[id]; ok {
+		return order, nil
+	}
+
+	now := time.Now()
+	slices := s.config.Slices
+	interval := time.Duration(s.config.WindowMinutes) * time.Minute / time.Duration(slices)
+
+	order := &TWAPOrder{
+		ID:         id,
+		InputMint:  inputMint,
+		OutputMint: outputMint,
+		Amount:     amount,
+		Children:   make([]TWAPChild, slices),
+		CreatedAt:  now,
+	}
+	for i := range order.Children {
+		order.Children[i] = TWAPChild{
+			Index:  i,
+			Amount: amount / float64(slices),
+			DueAt:  now.Add(time.Duration(i) * interval),
+			Status: ChildPending,
+		}
+	}
+	s.Orders[id] = order
+
+	utils.Info("Split order scheduled",
+		"order", id,
+		"output_mint", outputMint,
+		"amount", amount,
+		"slices", slices,
+		"interval", interval)
+
+	return order, s.save()
+}
+
+// Due returns the slices whose time has come, oldest order first
+func (s *TWAPScheduler) Due(now time.Time) []DueChild {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []DueChild
+	for _, order := range s.Orders {
+		for _, child := range order.Children {
+			if (child.Status == ChildPending || child.Status == ChildSubmitted) && !child.DueAt.After(now) {
+				due = append(due, DueChild{Order: order, Child: child})
+			}
+		}
+	}
+
+	sort.Slice(due, func(i, j int) bool {
+		if !due[i].Order.CreatedAt.Equal(due[j].Order.CreatedAt) {
+			return due[i].Order.CreatedAt.Before(due[j].Order.CreatedAt)
+		}
+		return due[i].Child.Index < due[j].Child.Index
+	})
+	return due
+}
+
+// Reserved returns the input still owed to unfinished slices of inputMint orders,
+// excluding the slice with triggerID
+func (s *TWAPScheduler) Reserved(inputMint, triggerID string) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reserved := 0.0
+	for _, order := range s.Orders {
+		if order.InputMint != inputMint {
+			continue
+		}
+		for _, child := range order.Children {
+			if child.Status == ChildDone || child.Status == ChildFailed {
+				continue
+			}
+			if order.ChildTriggerID(child.Index) == triggerID {
+				continue
+			}
+			reserved += child.Amount
+		}
+	}
+	return reserved
+}
+
+// MarkSubmitted records a transaction sent for a slice before it is broadcast
+func (s *TWAPScheduler) MarkSubmitted(orderID string, index int, sub Submission) {
+	s.update(orderID, index, func(child *TWAPChild) {
+		child.Status = ChildSubmitted
+		child.Submissions = append(child.Submissions, sub)
+	})
+}
+
+// Complete records the swap a slice produced
+func (s *TWAPScheduler) Complete(orderID string, index int, result *SwapResult) {
+	s.update(orderID, index, func(child *TWAPChild) {
+		child.Status = ChildDone
+		child.InputAmount = result.InputAmount
+		child.OutputAmount = result.OutputAmount
+		child.Signature = result.TxSignature
+		child.Error = ""
+	})
+}
+
+// Release clears the submissions of a slice once none of them can land anymore
+func (s *TWAPScheduler) Release(orderID string, index int) {
+	s.update(orderID, index, func(child *TWAPChild) {
+		child.Status = ChildPending
+		child.Submissions = nil
+	})
+}
+
+// Retry pushes a slice to a later tick, failing it once it ran out of attempts. A slice
+// held back by the buy conditions was never tried, so it doesn't use up an attempt.
+func (s *TWAPScheduler) Retry(orderID string, index int, err error) {
+	var skipped *BuySkippedError
+	counts := !errors.As(err, &skipped)
+
+	s.update(orderID, index, func(child *TWAPChild) {
+		if counts {
+			child.Attempts++
+		}
+		child.Error = err.Error()
+		if child.Attempts >= maxChildAttempts && len(child.Submissions) == 0 {
+			child.Status = ChildFailed
+			return
+		}
+		child.DueAt = time.Now().Add(time.Duration(s.config.WindowMinutes) * time.Minute / time.Duration(s.config.Slices))
+	})
+}
+
+// Finish removes an order once every slice is done or failed and returns it
+func (s *TWAPScheduler) Finish(orderID string) *TWAPOrder {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, ok := s.Orders[orderID]
+	if !ok || !order.Finished() {
+		return nil
+	}
+	delete(s.Orders, orderID)
+	if err := s.save(); err != nil {
+		utils.Warn("Failed to save split orders", "error", err)
+	}
+	return order
+}
+
+// update applies fn to a slice and persists the result
+func (s *TWAPScheduler) update(orderID string, index int, fn func(*TWAPChild)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, ok := s.Orders[orderID]
+	if !ok || index < 0 || index >= len(order.Children) {
+		return
+	}
+	fn(&order.Children[index])
+
+	if err := s.save(); err != nil {
+		utils.Warn("Failed to save split orders", "order", orderID, "error", err)
+	}
+}
+
+// save writes the orders to disk, the caller must hold the lock
+func (s *TWAPScheduler) save() error {
+	s.UpdatedAt = time.Now()
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write to a temp file first so a crash can't corrupt the orders
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
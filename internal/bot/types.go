@@ -4,19 +4,33 @@ import (
 	"time"
 
 	"github.com/magooney-loon/token-2022-refill-bot/internal/config"
+	"github.com/magooney-loon/token-2022-refill-bot/pkg/wallet"
 
 	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
 )
 
 // Bot represents the main bot instance
 type Bot struct {
-	config    *config.Config
-	wallet    *solana.Wallet
-	isRunning bool
-	stopChan  chan struct{}
-	errorChan chan error
-	stateChan chan State
-	trader    *Trader
+	config       *config.Config
+	wallet       *solana.Wallet
+	isRunning    bool
+	stopChan     chan struct{}
+	errorChan    chan error
+	stateChan    chan State
+	trader       *Trader
+	allocator    *Allocator
+	rebalancer   *Rebalancer
+	exits        *ExitManager
+	costBasis    *CostBasisBook
+	buyGuard     *BuyGuard
+	twap         *TWAPScheduler
+	rpcClient    *rpc.Client
+	ledger       *PaperLedger
+	mintAccounts map[string]*WatchedAccount // Token accounts balances are read from, by mint
+	monitor      *Monitor
+	dividends    *DividendWatcher
+	recheck      *time.Timer // Re-checks the balance when a held refill may run again
 }
 
 // State represents the current bot state
@@ -25,8 +39,15 @@ type State struct {
 	LastSwapAmount float64
 	LastSwapTime   time.Time
 	TotalSwaps     int64
+	TotalFees      float64
 	Errors         int64
 	Status         Status
+
+	// Refill hysteresis
+	CooldownUntil       time.Time // No refill before this time after a swap
+	Disarmed            bool      // Set after a refill until the balance drops below the re-arm level
+	ConsecutiveFailures int       // Failed refills in a row
+	BackoffUntil        time.Time // No refill before this time after failures
 }
 
 // Status represents the bot's operational status
@@ -40,20 +61,65 @@ const (
 	StatusStopped  Status = "STOPPED"
 )
 
+// SwapOrder describes a single swap for the trader to execute
+type SwapOrder struct {
+	TriggerID  string
+	Reason     string // Why the swap is made, recorded in the trade journal
+	InputMint  string
+	OutputMint string
+	Amount     float64 // Input amount in human units
+	Balance    float64 // Input balance available to this order
+
+	// OnSubmit is called with every transaction sent for the order, before it is broadcast
+	OnSubmit func(Submission)
+}
+
+// ScheduledTrigger is a buy emitted by the scheduler
+type ScheduledTrigger struct {
+	ID          string
+	ScheduledAt time.Time
+	Amount      float64
+	CatchUp     bool // Run missed while the bot was down
+}
+
+// DividendTrigger is a dividend payout detected by the dividend watcher
+type DividendTrigger struct {
+	ID       string
+	Transfer wallet.DividendTransfer
+}
+
+// Submission identifies a sent swap transaction so its outcome can be looked up later
+type Submission struct {
+	Signature            string `json:"signature"`
+	LastValidBlockHeight uint64 `json:"last_valid_block_height"`
+}
+
 // SwapResult contains information about a completed swap
 type SwapResult struct {
+	InputMint    string
+	OutputMint   string
 	InputAmount  float64
 	OutputAmount float64
+	QuotedOutput float64
 	Fee          float64
+	PriorityFee  float64
 	Timestamp    time.Time
 	TxSignature  string
+	Slot         uint64
+	Status       string
 	Route        string
 	PriceImpact  float64
 }
 
+// StatusSimulated marks a SwapResult produced in dry-run mode
+const StatusSimulated = "simulated"
+
 // BalanceCheck contains information about a balance check
 type BalanceCheck struct {
-	Balance   float64
+	ID        string
+	Mint      string  // Watched mint, the native mint for SOL
+	Amount    uint64  // Raw balance in the mint's smallest unit
+	Balance   float64 // Balance in human units
 	Timestamp time.Time
 	MetTarget bool
 	Error     error
@@ -78,14 +144,17 @@ func WithErrorBuffer(size int) BotOption {
 
 // BotStats contains statistics about the bot's operation
 type BotStats struct {
-	StartTime        time.Time
+	StartTime        time.Time // Start of the latest run
+	Uptime           time.Duration
 	TotalSwaps       int64
 	SuccessfulSwaps  int64
 	FailedSwaps      int64
-	TotalVolume      float64
-	AverageSlippage  float64
+	SuccessRate      float64 // Percent of swaps that succeeded
+	TotalVolume      float64 // USD value of the input swapped
+	AverageSlippage  float64 // Percent of quoted output not received
 	HighestSlippage  float64
 	AverageFee       float64
 	TotalFees        float64
-	UptimePercentage float64
+	UptimePercentage float64 // Percent of the time since the first run spent running
+	Tokens           map[string]*TokenStats
 }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
@@ -15,6 +16,7 @@ type Config struct {
 	Token   TokenConfig   `yaml:"token"`
 	Monitor MonitorConfig `yaml:"monitor"`
 	Jupiter JupiterConfig `yaml:"jupiter"`
+	Trading TradingConfig `yaml:"trading"`
 	Logging LoggingConfig `yaml:"logging"`
 }
 
@@ -22,38 +24,171 @@ type WalletConfig struct {
 	PrivateKey    string  `yaml:"private_key"`
 	MinSolBalance float64 `yaml:"min_sol_balance"`
 	ReserveAmount float64 `yaml:"reserve_amount"`
+	MinFeeBalance float64 `yaml:"min_fee_balance"`
 }
 
 type RPCConfig struct {
-	Endpoint       string `yaml:"endpoint"`
-	RetryAttempts  int    `yaml:"retry_attempts"`
-	TimeoutSeconds int    `yaml:"timeout_seconds"`
+	Endpoint                   string `yaml:"endpoint"`
+	RetryAttempts              int    `yaml:"retry_attempts"`
+	TimeoutSeconds             int    `yaml:"timeout_seconds"`
+	Commitment                 string `yaml:"commitment"`
+	ConfirmTimeoutSeconds      int    `yaml:"confirm_timeout_seconds"`
+	RebroadcastIntervalSeconds int    `yaml:"rebroadcast_interval_seconds"`
+	WSEndpoint                 string `yaml:"ws_endpoint"`
 }
 
 type TokenConfig struct {
-	InputMint       string  `yaml:"input_mint" validate:"required"`
-	OutputMint      string  `yaml:"output_mint" validate:"required"`
-	DividendMint    string  `yaml:"dividend_mint"`
-	SwapAmount      float64 `yaml:"swap_amount" validate:"required,gt=0"`
-	SlippageBPS     uint64  `yaml:"slippage_bps" validate:"required,gt=0"`
-	ProgramID       string  `yaml:"program_id" validate:"required"`
-	RefreshCache    bool    `yaml:"refresh_cache"`
-	CacheTTLMinutes int     `yaml:"cache_ttl_minutes" validate:"required,gt=0"`
+	InputMint       string         `yaml:"input_mint" validate:"required"`
+	OutputMint      string         `yaml:"output_mint"`
+	Targets         []TargetConfig `yaml:"targets"`
+	DividendMint    string         `yaml:"dividend_mint"`
+	SwapAmount      float64        `yaml:"swap_amount"`
+	SizingStrategy  string         `yaml:"sizing_strategy"`
+	SurplusPercent  float64        `yaml:"surplus_percent"`
+	MaxSwapAmount   float64        `yaml:"max_swap_amount"`
+	SlippageBPS     uint64         `yaml:"slippage_bps" validate:"required,gt=0"`
+	ProgramID       string         `yaml:"program_id" validate:"required"`
+	RefreshCache    bool           `yaml:"refresh_cache"`
+	CacheTTLMinutes int            `yaml:"cache_ttl_minutes" validate:"required,gt=0"`
+}
+
+// TargetConfig is an output token with its target weight in the portfolio
+type TargetConfig struct {
+	Mint        string  `yaml:"mint"`
+	Weight      float64 `yaml:"weight"`
+	MaxPriceUSD float64 `yaml:"max_price_usd"`
+}
+
+// IsTarget reports whether mint is one of the tokens the bot buys
+func (t *TokenConfig) IsTarget(mint string) bool {
+	for _, target := range t.Targets {
+		if target.Mint == mint {
+			return true
+		}
+	}
+	return false
 }
 
 type MonitorConfig struct {
-	CheckIntervalMinutes int `yaml:"check_interval_minutes"`
-	MaxRetries           int `yaml:"max_retries"`
-	RetryDelaySeconds    int `yaml:"retry_delay_seconds"`
+	CheckIntervalMinutes int     `yaml:"check_interval_minutes"`
+	MaxRetries           int     `yaml:"max_retries"`
+	RetryDelaySeconds    int     `yaml:"retry_delay_seconds"`
+	Mode                 string  `yaml:"mode"`
+	Schedule             string  `yaml:"schedule"`
+	ScheduleAmount       float64 `yaml:"schedule_amount"`
+	CatchUp              string  `yaml:"catch_up"`
+	Websocket            bool    `yaml:"websocket"`
+	CooldownMinutes      int     `yaml:"cooldown_minutes"`
+	RearmBalance         float64 `yaml:"rearm_balance"`
+	BackoffBaseSeconds   int     `yaml:"backoff_base_seconds"`
+	MaxBackoffMinutes    int     `yaml:"max_backoff_minutes"`
+	WatchMint            string  `yaml:"watch_mint"`
+	MinTokenBalance      float64 `yaml:"min_token_balance"`
+	TokenReserveAmount   float64 `yaml:"token_reserve_amount"`
+	TokenSwapAmount      float64 `yaml:"token_swap_amount"`
+	TokenMaxSwapAmount   float64 `yaml:"token_max_swap_amount"`
+}
+
+// Threshold returns the balance of the watched mint that triggers a refill
+func (c *Config) Threshold() float64 {
+	if c.Monitor.WatchMint != "" {
+		return c.Monitor.MinTokenBalance
+	}
+	return c.Wallet.MinSolBalance
+}
+
+// RefillMint returns the token threshold refills spend, the watched one if any
+func (c *Config) RefillMint() string {
+	if c.Monitor.WatchMint != "" {
+		return c.Monitor.WatchMint
+	}
+	return c.Token.InputMint
+}
+
+// RefillReserve returns the balance of the refill mint that refills leave untouched
+func (c *Config) RefillReserve() float64 {
+	if c.Monitor.WatchMint != "" {
+		return c.Monitor.TokenReserveAmount
+	}
+	return c.Wallet.ReserveAmount
+}
+
+// RefillSizing returns the sizing settings of threshold refills. A watched token has its
+// own amounts since the token settings are in units of the input token.
+func (c *Config) RefillSizing() *TokenConfig {
+	if c.Monitor.WatchMint == "" {
+		return &c.Token
+	}
+	sizing := c.Token
+	sizing.SwapAmount = c.Monitor.TokenSwapAmount
+	sizing.MaxSwapAmount = c.Monitor.TokenMaxSwapAmount
+	return &sizing
+}
+
+// IsCash reports whether mint is one the bot spends rather than accumulates
+func (c *Config) IsCash(mint string) bool {
+	return mint == c.Token.InputMint || (c.Monitor.WatchMint != "" && mint == c.Monitor.WatchMint)
 }
 
 type JupiterConfig struct {
-	QuoteEndpoint      string `yaml:"quote_endpoint"`
-	SwapEndpoint       string `yaml:"swap_endpoint"`
-	TokenAPIEndpoint   string `yaml:"token_api_endpoint"`
-	TokenPriceEndpoint string `yaml:"token_price_endpoint"`
-	OnlyDirectRoutes   bool   `yaml:"only_direct_routes"`
-	StrictTokenList    bool   `yaml:"strict_token_list"`
+	QuoteEndpoint      string   `yaml:"quote_endpoint"`
+	SwapEndpoint       string   `yaml:"swap_endpoint"`
+	TokenAPIEndpoint   string   `yaml:"token_api_endpoint"`
+	TokenPriceEndpoint string   `yaml:"token_price_endpoint"`
+	OnlyDirectRoutes   bool     `yaml:"only_direct_routes"`
+	StrictTokenList    bool     `yaml:"strict_token_list"`
+	AllowedPrograms    []string `yaml:"allowed_programs"`
+}
+
+type TradingConfig struct {
+	DryRun       bool              `yaml:"dry_run"`
+	PaperBalance float64           `yaml:"paper_balance"`
+	PriorityFee  PriorityFeeConfig `yaml:"priority_fee"`
+	Rebalance    RebalanceConfig   `yaml:"rebalance"`
+	Exits        ExitConfig        `yaml:"exits"`
+	BuyGuard     BuyGuardConfig    `yaml:"buy_guard"`
+	TWAP         TWAPConfig        `yaml:"twap"`
+	JournalDir   string            `yaml:"journal_dir"`
+	PnLMethod    string            `yaml:"pnl_method"`
+}
+
+type PriorityFeeConfig struct {
+	Strategy             string  `yaml:"strategy"`
+	FixedLamports        uint64  `yaml:"fixed_lamports"`
+	MaxLamports          uint64  `yaml:"max_lamports"`
+	AutoLevel            string  `yaml:"auto_level"`
+	Percentile           int     `yaml:"percentile"`
+	EscalationMultiplier float64 `yaml:"escalation_multiplier"`
+}
+
+type RebalanceConfig struct {
+	Enabled          bool    `yaml:"enabled"`
+	DriftBandPercent float64 `yaml:"drift_band_percent"`
+	SellInto         string  `yaml:"sell_into"`
+	MinTradeUSD      float64 `yaml:"min_trade_usd"`
+	MaxFeePercent    float64 `yaml:"max_fee_percent"`
+}
+
+type ExitConfig struct {
+	Enabled               bool    `yaml:"enabled"`
+	TakeProfitPercent     float64 `yaml:"take_profit_percent"`
+	TakeProfitSellPercent float64 `yaml:"take_profit_sell_percent"`
+	StopLossPercent       float64 `yaml:"stop_loss_percent"`
+	RebuyProceeds         bool    `yaml:"rebuy_proceeds"`
+}
+
+type BuyGuardConfig struct {
+	MaxPriceUSD         float64 `yaml:"max_price_usd"`
+	MaxDeviationPercent float64 `yaml:"max_deviation_percent"`
+	AverageWindow       int     `yaml:"average_window"`
+	BetterThanLastBuy   bool    `yaml:"better_than_last_buy"`
+}
+
+type TWAPConfig struct {
+	Enabled       bool    `yaml:"enabled"`
+	MinAmount     float64 `yaml:"min_amount"`
+	Slices        int     `yaml:"slices"`
+	WindowMinutes int     `yaml:"window_minutes"`
 }
 
 type LoggingConfig struct {
@@ -79,6 +214,9 @@ func LoadConfig(configPath string) (*Config, error) {
 		return nil, fmt.Errorf("error parsing config file: %w", err)
 	}
 
+	// Fill in optional settings
+	applyDefaults(config)
+
 	// Validate config
 	if err := validateConfig(config); err != nil {
 		return nil, fmt.Errorf("config validation error: %w", err)
@@ -93,6 +231,117 @@ func LoadConfig(configPath string) (*Config, error) {
 	return config, nil
 }
 
+// applyDefaults fills in optional settings that were left empty
+func applyDefaults(config *Config) {
+	if config.Token.SizingStrategy == "" {
+		config.Token.SizingStrategy = "fixed"
+	}
+
+	// A single output mint is a portfolio with one target
+	if len(config.Token.Targets) == 0 && config.Token.OutputMint != "" {
+		config.Token.Targets = []TargetConfig{{Mint: config.Token.OutputMint, Weight: 100}}
+	}
+	if config.Token.OutputMint == "" && len(config.Token.Targets) > 0 {
+		config.Token.OutputMint = config.Token.Targets[0].Mint
+	}
+
+	if config.Monitor.Mode == "" {
+		config.Monitor.Mode = "threshold"
+	}
+	if config.Monitor.CatchUp == "" {
+		config.Monitor.CatchUp = "skip"
+	}
+	if config.Monitor.BackoffBaseSeconds == 0 {
+		config.Monitor.BackoffBaseSeconds = 30
+	}
+	if config.Monitor.MaxBackoffMinutes == 0 {
+		config.Monitor.MaxBackoffMinutes = 60
+	}
+
+	// The websocket endpoint usually lives on the same host as the HTTP one
+	if (config.Monitor.Websocket || config.Monitor.Mode == "dividend") && config.RPC.WSEndpoint == "" {
+		endpoint := config.RPC.Endpoint
+		if rest, ok := strings.CutPrefix(endpoint, "https://"); ok {
+			endpoint = "wss://" + rest
+		} else if rest, ok := strings.CutPrefix(endpoint, "http://"); ok {
+			endpoint = "ws://" + rest
+		}
+		config.RPC.WSEndpoint = endpoint
+	}
+
+	if config.Wallet.MinFeeBalance == 0 {
+		config.Wallet.MinFeeBalance = 0.01
+	}
+
+	if config.RPC.Commitment == "" {
+		config.RPC.Commitment = "confirmed"
+	}
+
+	if config.RPC.ConfirmTimeoutSeconds <= 0 {
+		config.RPC.ConfirmTimeoutSeconds = 90
+	}
+
+	if config.RPC.RebroadcastIntervalSeconds <= 0 {
+		config.RPC.RebroadcastIntervalSeconds = 2
+	}
+
+	fee := &config.Trading.PriorityFee
+	if fee.Strategy == "" {
+		fee.Strategy = "fixed"
+	}
+	if fee.FixedLamports == 0 {
+		fee.FixedLamports = 36699
+	}
+	if fee.MaxLamports == 0 {
+		fee.MaxLamports = 1000000
+	}
+	if fee.AutoLevel == "" {
+		fee.AutoLevel = "high"
+	}
+	if fee.Percentile == 0 {
+		fee.Percentile = 75
+	}
+	if fee.EscalationMultiplier == 0 {
+		fee.EscalationMultiplier = 1.5
+	}
+
+	rebalance := &config.Trading.Rebalance
+	if rebalance.DriftBandPercent == 0 {
+		rebalance.DriftBandPercent = 5
+	}
+	if rebalance.SellInto == "" {
+		rebalance.SellInto = "sol"
+	}
+	if rebalance.MinTradeUSD == 0 {
+		rebalance.MinTradeUSD = 5
+	}
+	if rebalance.MaxFeePercent == 0 {
+		rebalance.MaxFeePercent = 1
+	}
+
+	if config.Trading.Exits.TakeProfitSellPercent == 0 {
+		config.Trading.Exits.TakeProfitSellPercent = 25
+	}
+
+	if config.Trading.BuyGuard.AverageWindow <= 0 {
+		config.Trading.BuyGuard.AverageWindow = 12
+	}
+
+	if config.Trading.TWAP.Slices == 0 {
+		config.Trading.TWAP.Slices = 4
+	}
+	if config.Trading.TWAP.WindowMinutes == 0 {
+		config.Trading.TWAP.WindowMinutes = 20
+	}
+
+	if config.Trading.JournalDir == "" {
+		config.Trading.JournalDir = "data"
+	}
+	if config.Trading.PnLMethod == "" {
+		config.Trading.PnLMethod = "average"
+	}
+}
+
 // validateConfig performs basic validation of the configuration
 func validateConfig(config *Config) error {
 	if config.Wallet.PrivateKey == "" {
@@ -107,17 +356,178 @@ func validateConfig(config *Config) error {
 		return fmt.Errorf("at least one token mint address must be provided")
 	}
 
-	if config.Wallet.MinSolBalance <= 0 {
+	for _, target := range config.Token.Targets {
+		if target.Mint == "" {
+			return fmt.Errorf("target mint address is required")
+		}
+		if target.Weight <= 0 {
+			return fmt.Errorf("target %s weight must be greater than 0", target.Mint)
+		}
+		if target.Mint == config.Token.InputMint {
+			return fmt.Errorf("target %s cannot be the input mint", target.Mint)
+		}
+		if target.Mint == config.Monitor.WatchMint {
+			return fmt.Errorf("target %s cannot be the watched mint", target.Mint)
+		}
+		if target.MaxPriceUSD < 0 {
+			return fmt.Errorf("target %s max price cannot be negative", target.Mint)
+		}
+	}
+
+	if config.Monitor.WatchMint == "" && config.Wallet.MinSolBalance <= 0 {
 		return fmt.Errorf("minimum SOL balance must be greater than 0")
 	}
 
-	if config.Token.SwapAmount <= 0 {
-		return fmt.Errorf("swap amount must be greater than 0")
+	if config.Wallet.MinFeeBalance < 0 {
+		return fmt.Errorf("minimum fee balance cannot be negative")
+	}
+
+	// Threshold refills are sized with the watched token's amounts when one is watched
+	sizing := config.RefillSizing()
+	switch sizing.SizingStrategy {
+	case "fixed":
+		if sizing.SwapAmount <= 0 {
+			return fmt.Errorf("swap amount must be greater than 0")
+		}
+	case "surplus":
+	case "percent":
+		if sizing.SurplusPercent <= 0 || sizing.SurplusPercent > 100 {
+			return fmt.Errorf("surplus percent must be between 0 and 100")
+		}
+	case "capped":
+		if sizing.MaxSwapAmount <= 0 {
+			return fmt.Errorf("capped sizing requires max swap amount greater than 0")
+		}
+	default:
+		return fmt.Errorf("invalid sizing strategy %q: must be fixed, surplus, percent or capped", sizing.SizingStrategy)
+	}
+
+	if sizing.MaxSwapAmount < 0 || config.Monitor.TokenReserveAmount < 0 {
+		return fmt.Errorf("max swap amount and token reserve cannot be negative")
 	}
 
 	if config.Monitor.CheckIntervalMinutes <= 0 {
 		return fmt.Errorf("check interval must be greater than 0")
 	}
 
+	if config.Monitor.CooldownMinutes < 0 || config.Monitor.BackoffBaseSeconds < 0 || config.Monitor.MaxBackoffMinutes < 0 {
+		return fmt.Errorf("cooldown and backoff cannot be negative")
+	}
+
+	if config.Monitor.WatchMint != "" && config.Monitor.MinTokenBalance <= 0 {
+		return fmt.Errorf("watching %s requires min token balance greater than 0", config.Monitor.WatchMint)
+	}
+
+	if config.Monitor.WatchMint != "" && config.Monitor.Mode == "dividend" {
+		return fmt.Errorf("monitor mode dividend watches SOL payouts and can't be combined with a watched mint")
+	}
+
+	if config.Monitor.RearmBalance < 0 || (config.Monitor.RearmBalance > 0 && config.Monitor.RearmBalance >= config.Threshold()) {
+		return fmt.Errorf("re-arm balance must be below the trigger threshold")
+	}
+
+	switch config.Monitor.Mode {
+	case "threshold":
+	case "schedule", "both":
+		if config.Monitor.Schedule == "" {
+			return fmt.Errorf("monitor mode %q requires a schedule", config.Monitor.Mode)
+		}
+		if config.Monitor.ScheduleAmount <= 0 {
+			return fmt.Errorf("scheduled buy amount must be greater than 0")
+		}
+	case "dividend":
+		if config.Token.DividendMint == "" {
+			return fmt.Errorf("monitor mode dividend requires a dividend address")
+		}
+		if config.Token.InputMint != "So11111111111111111111111111111111111111112" {
+			return fmt.Errorf("monitor mode dividend requires SOL as the input token")
+		}
+	default:
+		return fmt.Errorf("invalid monitor mode %q: must be threshold, schedule, both or dividend", config.Monitor.Mode)
+	}
+
+	switch config.Monitor.CatchUp {
+	case "skip", "once", "all":
+	default:
+		return fmt.Errorf("invalid catch up policy %q: must be skip, once or all", config.Monitor.CatchUp)
+	}
+
+	if config.Trading.PaperBalance < 0 {
+		return fmt.Errorf("paper balance cannot be negative")
+	}
+
+	switch config.Trading.PnLMethod {
+	case "average", "fifo":
+	default:
+		return fmt.Errorf("invalid P&L method %q: must be average or fifo", config.Trading.PnLMethod)
+	}
+
+	switch config.Trading.PriorityFee.Strategy {
+	case "fixed", "auto", "percentile":
+	default:
+		return fmt.Errorf("invalid priority fee strategy %q: must be fixed, auto or percentile", config.Trading.PriorityFee.Strategy)
+	}
+
+	switch config.Trading.PriorityFee.AutoLevel {
+	case "medium", "high", "veryHigh":
+	default:
+		return fmt.Errorf("invalid priority fee auto level %q: must be medium, high or veryHigh", config.Trading.PriorityFee.AutoLevel)
+	}
+
+	if config.Trading.PriorityFee.Percentile < 1 || config.Trading.PriorityFee.Percentile > 100 {
+		return fmt.Errorf("priority fee percentile must be between 1 and 100")
+	}
+
+	if config.Trading.PriorityFee.EscalationMultiplier < 1 {
+		return fmt.Errorf("priority fee escalation multiplier must be at least 1")
+	}
+
+	if config.Trading.Rebalance.DriftBandPercent < 0 || config.Trading.Rebalance.DriftBandPercent >= 100 {
+		return fmt.Errorf("rebalance drift band must be between 0 and 100")
+	}
+
+	switch config.Trading.Rebalance.SellInto {
+	case "sol", "underweight":
+	default:
+		return fmt.Errorf("invalid rebalance sell_into %q: must be sol or underweight", config.Trading.Rebalance.SellInto)
+	}
+
+	if config.Trading.Rebalance.MinTradeUSD < 0 || config.Trading.Rebalance.MaxFeePercent < 0 {
+		return fmt.Errorf("rebalance min trade and max fee cannot be negative")
+	}
+
+	exits := config.Trading.Exits
+	if exits.TakeProfitPercent < 0 {
+		return fmt.Errorf("take profit percent cannot be negative")
+	}
+	if exits.TakeProfitSellPercent <= 0 || exits.TakeProfitSellPercent > 100 {
+		return fmt.Errorf("take profit sell percent must be between 0 and 100")
+	}
+	if exits.StopLossPercent < 0 || exits.StopLossPercent >= 100 {
+		return fmt.Errorf("stop loss percent must be between 0 and 100")
+	}
+
+	if config.Trading.BuyGuard.MaxPriceUSD < 0 || config.Trading.BuyGuard.MaxDeviationPercent < 0 {
+		return fmt.Errorf("buy guard max price and max deviation cannot be negative")
+	}
+
+	if config.Trading.TWAP.Enabled {
+		if config.Trading.TWAP.MinAmount <= 0 {
+			return fmt.Errorf("twap min amount must be greater than 0")
+		}
+		if config.Trading.TWAP.Slices < 2 {
+			return fmt.Errorf("twap slices must be at least 2")
+		}
+		if config.Trading.TWAP.WindowMinutes < 1 {
+			return fmt.Errorf("twap window must be at least 1 minute")
+		}
+	}
+
+	switch config.RPC.Commitment {
+	case "processed", "confirmed", "finalized":
+	default:
+		return fmt.Errorf("invalid RPC commitment %q: must be processed, confirmed or finalized", config.RPC.Commitment)
+	}
+
 	return nil
 }
@@ -22,23 +22,39 @@ import (
 )
 
 type Client struct {
-	httpClient   *http.Client
-	rateLimiter  *rate.Limiter
-	config       *config.JupiterConfig
-	tokenClient  *token2022.Client
-	refreshCache bool
+	httpClient      *http.Client
+	rateLimiter     *rate.Limiter
+	config          *config.JupiterConfig
+	tokenClient     *token2022.Client
+	rpcClient       *rpc.Client
+	allowedPrograms map[solana.PublicKey]string
+	refreshCache    bool
 }
 
-func NewClient(cfg *config.JupiterConfig, tokenClient *token2022.Client, refreshCache bool) *Client {
+func NewClient(cfg *config.JupiterConfig, tokenClient *token2022.Client, rpcClient *rpc.Client, refreshCache bool) (*Client, error) {
+	allowed := make(map[solana.PublicKey]string, len(defaultAllowedPrograms)+len(cfg.AllowedPrograms))
+	for program, name := range defaultAllowedPrograms {
+		allowed[program] = name
+	}
+	for _, addr := range cfg.AllowedPrograms {
+		program, err := solana.PublicKeyFromBase58(addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowed program %s: %w", addr, err)
+		}
+		allowed[program] = "Custom"
+	}
+
 	return &Client{
 		httpClient: &http.Client{
 			Timeout: time.Second * 30,
 		},
-		rateLimiter:  rate.NewLimiter(rate.Limit(1), 1), // 1 request per second
-		config:       cfg,
-		tokenClient:  tokenClient,
-		refreshCache: refreshCache,
-	}
+		rateLimiter:     rate.NewLimiter(rate.Limit(1), 1), // 1 request per second
+		config:          cfg,
+		tokenClient:     tokenClient,
+		rpcClient:       rpcClient,
+		allowedPrograms: allowed,
+		refreshCache:    refreshCache,
+	}, nil
 }
 
 func (c *Client) GetQuote(ctx context.Context, inputMint, outputMint string, amount uint64, slippageBps int, dexes string) (*Quote, error) {
@@ -131,8 +147,12 @@ func (c *Client) GetQuote(ctx context.Context, inputMint, outputMint string, amo
 	return &quote, nil
 }
 
-func (c *Client) ExecuteSwap(ctx context.Context, wallet *solana.Wallet, quote *Quote, rpcClient *rpc.Client, priorityFee int) (solana.Signature, error) {
-	utils.Info("🚀 Initiating Swap Transaction",
+// BuildSwapTransaction requests a swap transaction for the quote, verifies it and signs it.
+// The transaction is not sent; the caller owns broadcasting it. A dry run is verified but
+// never signed, and since its simulation runs against the real wallet, which may hold less
+// than the paper balance, a failed simulation leaves Simulation nil instead of rejecting it.
+func (c *Client) BuildSwapTransaction(ctx context.Context, wallet *solana.Wallet, quote *Quote, priorityFee PriorityFee, dryRun bool) (*SwapTransaction, error) {
+	utils.Info("🚀 Building Swap Transaction",
 		"wallet", fmt.Sprintf("%s...%s", wallet.PublicKey().String()[:8], wallet.PublicKey().String()[len(wallet.PublicKey().String())-8:]),
 		"input", fmt.Sprintf("%s %s", quote.InAmount, quote.InputMint[:8]),
 		"output", fmt.Sprintf("%s %s", quote.OutAmount, quote.OutputMint[:8]),
@@ -141,7 +161,7 @@ func (c *Client) ExecuteSwap(ctx context.Context, wallet *solana.Wallet, quote *
 	// Check if output token is Token-2022
 	outputToken, err := c.tokenClient.GetTokenInfo(ctx, quote.OutputMint)
 	if err != nil {
-		return solana.Signature{}, fmt.Errorf("failed to get output token info: %w", err)
+		return nil, fmt.Errorf("failed to get output token info: %w", err)
 	}
 
 	isToken2022 := false
@@ -153,17 +173,15 @@ func (c *Client) ExecuteSwap(ctx context.Context, wallet *solana.Wallet, quote *
 	}
 
 	swapRequest := SwapRequest{
-		UserPublicKey:             wallet.PublicKey().String(),
-		WrapAndUnwrapSol:          true,
-		UseSharedAccounts:         true, // Enable shared accounts for better efficiency
-		PrioritizationFeeLamports: priorityFee,
-		AsLegacyTransaction:       false,
-		UseTokenLedger:            false,
-		DynamicComputeUnitLimit:   true,
-		SkipUserAccountsRpcCalls:  true,
-		QuoteResponse:             *quote,
-		ComputeUnitLimit:          200000, // Default limit
-		ComputeUnitPrice:          0,      // Let Jupiter set this dynamically
+		UserPublicKey:            wallet.PublicKey().String(),
+		WrapAndUnwrapSol:         true,
+		UseSharedAccounts:        true, // Enable shared accounts for better efficiency
+		AsLegacyTransaction:      false,
+		UseTokenLedger:           false,
+		DynamicComputeUnitLimit:  true,
+		SkipUserAccountsRpcCalls: true,
+		QuoteResponse:            *quote,
+		ComputeUnitLimit:         200000, // Default limit
 	}
 
 	// Increase compute limit for Token-2022 tokens
@@ -171,8 +189,10 @@ func (c *Client) ExecuteSwap(ctx context.Context, wallet *solana.Wallet, quote *
 		swapRequest.ComputeUnitLimit = 400000
 	}
 
+	feeDescription := applyPriorityFee(&swapRequest, priorityFee)
+
 	utils.Debug("📝 Swap Request Details",
-		"priority_fee", fmt.Sprintf("%d lamports", priorityFee),
+		"priority_fee", feeDescription,
 		"compute_limit", swapRequest.ComputeUnitLimit,
 		"is_token2022", isToken2022,
 		"wrap_sol", true)
@@ -180,13 +200,43 @@ func (c *Client) ExecuteSwap(ctx context.Context, wallet *solana.Wallet, quote *
 	swapResp, err := c.submitSwapRequest(&swapRequest)
 	if err != nil {
 		utils.Error("❌ Failed to submit swap request", err)
-		return solana.Signature{}, err
+		return nil, err
 	}
 
 	utils.Debug("📦 Swap Response Received",
-		"tx_size", fmt.Sprintf("%d bytes", len(swapResp.SwapTransaction)))
+		"tx_size", fmt.Sprintf("%d bytes", len(swapResp.SwapTransaction)),
+		"last_valid_block_height", swapResp.LastValidBlockHeight,
+		"priority_fee", fmt.Sprintf("%d lamports", swapResp.PrioritizationFeeLamports))
 
-	return c.processSwapTransaction(ctx, swapResp, wallet, rpcClient)
+	return c.processSwapTransaction(ctx, swapResp, quote, wallet, dryRun)
+}
+
+// applyPriorityFee sets the request's priority fee fields and describes the choice for logging
+func applyPriorityFee(req *SwapRequest, fee PriorityFee) string {
+	switch {
+	case fee.MicroLamportsPerCU > 0:
+		price := fee.MicroLamportsPerCU
+		// Keep the total fee under the cap at the expected compute limit
+		if fee.MaxLamports > 0 && req.ComputeUnitLimit > 0 {
+			maxPrice := fee.MaxLamports * 1_000_000 / uint64(req.ComputeUnitLimit)
+			if price > maxPrice {
+				price = maxPrice
+			}
+		}
+		req.ComputeUnitPriceMicroLamports = price
+		return fmt.Sprintf("%d micro-lamports/CU", price)
+	case fee.AutoLevel != "":
+		req.PrioritizationFeeLamports = PriorityLevelFee{
+			PriorityLevelWithMaxLamports: PriorityLevelWithMaxLamports{
+				MaxLamports:   fee.MaxLamports,
+				PriorityLevel: fee.AutoLevel,
+			},
+		}
+		return fmt.Sprintf("auto %s (max %d lamports)", fee.AutoLevel, fee.MaxLamports)
+	default:
+		req.PrioritizationFeeLamports = fee.Lamports
+		return fmt.Sprintf("%d lamports", fee.Lamports)
+	}
 }
 
 func (c *Client) submitSwapRequest(req *SwapRequest) (*SwapResponse, error) {
@@ -220,20 +270,46 @@ func (c *Client) submitSwapRequest(req *SwapRequest) (*SwapResponse, error) {
 	return &swapResp, nil
 }
 
-func (c *Client) processSwapTransaction(ctx context.Context, swapResp *SwapResponse, wallet *solana.Wallet, rpcClient *rpc.Client) (solana.Signature, error) {
+func (c *Client) processSwapTransaction(ctx context.Context, swapResp *SwapResponse, quote *Quote, wallet *solana.Wallet, dryRun bool) (*SwapTransaction, error) {
 	utils.Info("🔄 Processing Swap Transaction",
 		"tx_size", fmt.Sprintf("%d bytes", len(swapResp.SwapTransaction)))
 
 	txBytes, err := base64.StdEncoding.DecodeString(swapResp.SwapTransaction)
 	if err != nil {
 		utils.Error("❌ Failed to decode transaction", err)
-		return solana.Signature{}, fmt.Errorf("failed to decode swap transaction: %w", err)
+		return nil, fmt.Errorf("failed to decode swap transaction: %w", err)
 	}
 
 	tx, err := solana.TransactionFromDecoder(bin.NewBinDecoder(txBytes))
 	if err != nil {
 		utils.Error("❌ Failed to deserialize transaction", err)
-		return solana.Signature{}, fmt.Errorf("failed to deserialize transaction: %w", err)
+		return nil, fmt.Errorf("failed to deserialize transaction: %w", err)
+	}
+
+	// Never sign anything we haven't checked ourselves
+	if err := c.verifyInstructions(ctx, tx, quote, wallet.PublicKey()); err != nil {
+		utils.Error("🚫 Swap Transaction Rejected", err)
+		return nil, fmt.Errorf("swap transaction rejected: %w", err)
+	}
+
+	sim, err := c.simulateAndVerify(ctx, tx, quote, wallet.PublicKey(), swapResp.PrioritizationFeeLamports)
+	if err != nil && dryRun && sim != nil && sim.Err != nil {
+		utils.Warn("⚠️ Dry run simulation failed against the real wallet, booking from the quote",
+			"error", err)
+		sim = nil
+	} else if err != nil {
+		utils.Error("🚫 Swap Simulation Rejected", err)
+		return nil, fmt.Errorf("swap simulation rejected: %w", err)
+	}
+
+	// A dry run never needs the wallet's signature
+	if dryRun {
+		return &SwapTransaction{
+			Transaction:          tx,
+			LastValidBlockHeight: swapResp.LastValidBlockHeight,
+			PrioritizationFee:    swapResp.PrioritizationFeeLamports,
+			Simulation:           sim,
+		}, nil
 	}
 
 	utils.Debug("✍️ Signing Transaction",
@@ -248,20 +324,20 @@ func (c *Client) processSwapTransaction(ctx context.Context, swapResp *SwapRespo
 	})
 	if err != nil {
 		utils.Error("❌ Failed to sign transaction", err)
-		return solana.Signature{}, fmt.Errorf("failed to sign transaction: %w", err)
+		return nil, fmt.Errorf("failed to sign transaction: %w", err)
 	}
 
-	utils.Info("📡 Sending Transaction to Solana")
-
-	sig, err := rpcClient.SendTransaction(ctx, tx)
-	if err != nil {
-		utils.Error("❌ Failed to send transaction", err)
-		return solana.Signature{}, fmt.Errorf("failed to send transaction: %w", err)
-	}
+	sig := tx.Signatures[0]
 
-	utils.Info("✅ Transaction Sent Successfully",
-		"signature", fmt.Sprintf("https://solscan.io/tx/%s", sig.String()),
-		"status", "processing")
+	utils.Info("✅ Transaction Signed",
+		"signature", sig.String(),
+		"last_valid_block_height", swapResp.LastValidBlockHeight)
 
-	return sig, nil
+	return &SwapTransaction{
+		Signature:            sig,
+		Transaction:          tx,
+		LastValidBlockHeight: swapResp.LastValidBlockHeight,
+		PrioritizationFee:    swapResp.PrioritizationFeeLamports,
+		Simulation:           sim,
+	}, nil
 }
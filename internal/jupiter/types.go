@@ -1,5 +1,10 @@
 package jupiter
 
+import (
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
 // Quote represents a Jupiter quote response
 type Quote struct {
 	InputMint            string  `json:"inputMint"`
@@ -38,19 +43,49 @@ type SwapInfo struct {
 }
 
 type SwapRequest struct {
-	UserPublicKey             string `json:"userPublicKey"`
-	WrapAndUnwrapSol          bool   `json:"wrapAndUnwrapSol"`
-	UseSharedAccounts         bool   `json:"useSharedAccounts"`
-	PrioritizationFeeLamports int    `json:"prioritizationFeeLamports"`
-	AsLegacyTransaction       bool   `json:"asLegacyTransaction"`
-	UseTokenLedger            bool   `json:"useTokenLedger"`
-	DynamicComputeUnitLimit   bool   `json:"dynamicComputeUnitLimit"`
-	SkipUserAccountsRpcCalls  bool   `json:"skipUserAccountsRpcCalls"`
-	QuoteResponse             Quote  `json:"quoteResponse"`
-	ComputeUnitLimit          int    `json:"computeUnitLimit"`
-	ComputeUnitPrice          int    `json:"computeUnitPrice"`
+	UserPublicKey                 string      `json:"userPublicKey"`
+	WrapAndUnwrapSol              bool        `json:"wrapAndUnwrapSol"`
+	UseSharedAccounts             bool        `json:"useSharedAccounts"`
+	PrioritizationFeeLamports     interface{} `json:"prioritizationFeeLamports,omitempty"`
+	ComputeUnitPriceMicroLamports uint64      `json:"computeUnitPriceMicroLamports,omitempty"`
+	AsLegacyTransaction           bool        `json:"asLegacyTransaction"`
+	UseTokenLedger                bool        `json:"useTokenLedger"`
+	DynamicComputeUnitLimit       bool        `json:"dynamicComputeUnitLimit"`
+	SkipUserAccountsRpcCalls      bool        `json:"skipUserAccountsRpcCalls"`
+	QuoteResponse                 Quote       `json:"quoteResponse"`
+	ComputeUnitLimit              int         `json:"computeUnitLimit"`
+}
+
+// PriorityLevelFee asks Jupiter to pick a priority fee at a level, up to a cap
+type PriorityLevelFee struct {
+	PriorityLevelWithMaxLamports PriorityLevelWithMaxLamports `json:"priorityLevelWithMaxLamports"`
+}
+
+type PriorityLevelWithMaxLamports struct {
+	MaxLamports   uint64 `json:"maxLamports"`
+	PriorityLevel string `json:"priorityLevel"`
+}
+
+// PriorityFee describes how a swap transaction's priority fee is set.
+// Exactly one of Lamports, AutoLevel or MicroLamportsPerCU is used, in reverse order of precedence.
+type PriorityFee struct {
+	Lamports           uint64 // Fixed total priority fee
+	AutoLevel          string // Let Jupiter pick a fee at this level: medium, high or veryHigh
+	MicroLamportsPerCU uint64 // Explicit compute unit price
+	MaxLamports        uint64 // Cap for auto and compute unit price fees
 }
 
 type SwapResponse struct {
-	SwapTransaction string `json:"swapTransaction"`
+	SwapTransaction           string `json:"swapTransaction"`
+	LastValidBlockHeight      uint64 `json:"lastValidBlockHeight"`
+	PrioritizationFeeLamports uint64 `json:"prioritizationFeeLamports"`
+}
+
+// SwapTransaction is a signed swap transaction ready to be broadcast
+type SwapTransaction struct {
+	Signature            solana.Signature // Zero for an unsigned dry run
+	Transaction          *solana.Transaction
+	LastValidBlockHeight uint64
+	PrioritizationFee    uint64
+	Simulation           *rpc.SimulateTransactionResult
 }
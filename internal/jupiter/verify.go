@@ -0,0 +1,365 @@
+package jupiter
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	"github.com/magooney-loon/token-2022-refill-bot/internal/utils"
+
+	"github.com/gagliardetto/solana-go"
+	addresslookuptable "github.com/gagliardetto/solana-go/programs/address-lookup-table"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+const (
+	nativeMint = "So11111111111111111111111111111111111111112"
+
+	// Lamports a SOL-input swap may spend beyond the quoted amount: signature fees
+	// and rent for up to two token accounts opened by the route
+	maxSolOverheadLamports = 10_000 + 2*2_500_000
+
+	// lamportsPerSignature is the base fee charged per transaction signature
+	lamportsPerSignature = 5_000
+)
+
+// Instructions a swap transaction may send to the System, Token and Associated Token
+// Account programs directly: wrapping SOL, opening the swap's token accounts and unwrapping
+const (
+	systemTransfer      = 2  // System Transfer, a little-endian u32 tag
+	tokenCloseAccount   = 9  // Token CloseAccount
+	tokenSyncNative     = 17 // Token SyncNative
+	ataCreate           = 0  // Associated Token Account Create, also sent without data
+	ataCreateIdempotent = 1  // Associated Token Account CreateIdempotent
+)
+
+// JupiterProgramID is the Jupiter aggregator v6 program
+var JupiterProgramID = solana.MustPublicKeyFromBase58("JUP6LkbZbjS1jKKwapdHNy74zcZ3tLUZoi5QNyVTaV4")
+
+// defaultAllowedPrograms are the only programs a swap transaction may invoke at the top level.
+// Instructions to the System, Token and Associated Token Account programs are further limited
+// to the kinds a swap needs, see verifyInstruction.
+var defaultAllowedPrograms = map[solana.PublicKey]string{
+	JupiterProgramID:                          "Jupiter",
+	solana.TokenProgramID:                     "Token",
+	solana.Token2022ProgramID:                 "Token-2022",
+	solana.SPLAssociatedTokenAccountProgramID: "Associated Token Account",
+	solana.SystemProgramID:                    "System",
+	solana.ComputeBudget:                      "Compute Budget",
+}
+
+// swapAccounts are the accounts a swap's own System, Token and Associated Token Account
+// instructions may use: the owner's token accounts of the quoted mints and its wrapped SOL account
+type swapAccounts struct {
+	owner         solana.PublicKey
+	mints         map[solana.PublicKey]bool
+	tokenAccounts map[solana.PublicKey]bool
+	wrapped       solana.PublicKey // Zero unless SOL is swapped
+}
+
+// newSwapAccounts derives the accounts a swap of the quoted mints may touch directly
+func newSwapAccounts(owner solana.PublicKey, quote *Quote) (*swapAccounts, error) {
+	accounts := &swapAccounts{
+		owner:         owner,
+		mints:         make(map[solana.PublicKey]bool, 2),
+		tokenAccounts: make(map[solana.PublicKey]bool, 4),
+	}
+	for _, mint := range []string{quote.InputMint, quote.OutputMint} {
+		mintKey, err := solana.PublicKeyFromBase58(mint)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mint %s: %w", mint, err)
+		}
+		atas, err := tokenAccounts(owner, mintKey)
+		if err != nil {
+			return nil, err
+		}
+
+		accounts.mints[mintKey] = true
+		for _, ata := range atas {
+			accounts.tokenAccounts[ata] = true
+		}
+		// Wrapped SOL belongs to the original Token program
+		if mint == nativeMint {
+			accounts.wrapped = atas[0]
+		}
+	}
+	return accounts, nil
+}
+
+// verifyInstructions checks the fee payer, every top-level program against the allowlist,
+// and what the swap asks the System, Token and Associated Token Account programs to do
+func (c *Client) verifyInstructions(ctx context.Context, tx *solana.Transaction, quote *Quote, owner solana.PublicKey) error {
+	if len(tx.Message.AccountKeys) == 0 || !tx.Message.AccountKeys[0].Equals(owner) {
+		return fmt.Errorf("fee payer is not the wallet")
+	}
+
+	for _, signer := range tx.Message.Signers() {
+		if !signer.Equals(owner) {
+			return fmt.Errorf("transaction requires unexpected signer %s", signer)
+		}
+	}
+
+	allowed, err := newSwapAccounts(owner, quote)
+	if err != nil {
+		return err
+	}
+	keys, err := c.accountKeys(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	for i, inst := range tx.Message.Instructions {
+		programID, err := tx.Message.Program(inst.ProgramIDIndex)
+		if err != nil {
+			return fmt.Errorf("instruction %d: %w", i, err)
+		}
+		if _, ok := c.allowedPrograms[programID]; !ok {
+			return fmt.Errorf("instruction %d invokes program %s which is not allowed", i, programID)
+		}
+
+		accounts := make([]solana.PublicKey, len(inst.Accounts))
+		for j, index := range inst.Accounts {
+			if int(index) >= len(keys) {
+				return fmt.Errorf("instruction %d references account %d of %d", i, index, len(keys))
+			}
+			accounts[j] = keys[index]
+		}
+		if err := verifyInstruction(programID, accounts, inst.Data, allowed); err != nil {
+			return fmt.Errorf("instruction %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// verifyInstruction limits System, Token and Associated Token Account instructions to
+// wrapping SOL into the owner's wrapped SOL account, opening the owner's token accounts of
+// the quoted mints, and closing the wrapped SOL account back to the owner
+func verifyInstruction(programID solana.PublicKey, accounts []solana.PublicKey, data []byte, allowed *swapAccounts) error {
+	switch programID {
+	case solana.SystemProgramID:
+		if len(data) < 4 || binary.LittleEndian.Uint32(data) != systemTransfer {
+			return fmt.Errorf("system instruction is not a transfer")
+		}
+		if len(accounts) < 2 || !accounts[0].Equals(allowed.owner) || allowed.wrapped.IsZero() || !accounts[1].Equals(allowed.wrapped) {
+			return fmt.Errorf("system transfer is not from the wallet to its wrapped SOL account")
+		}
+
+	case solana.TokenProgramID, solana.Token2022ProgramID:
+		if len(data) == 0 {
+			return fmt.Errorf("empty token instruction")
+		}
+		switch data[0] {
+		case tokenSyncNative:
+			if len(accounts) < 1 || allowed.wrapped.IsZero() || !accounts[0].Equals(allowed.wrapped) {
+				return fmt.Errorf("sync native is not on the wallet's wrapped SOL account")
+			}
+		case tokenCloseAccount:
+			if len(accounts) < 3 || allowed.wrapped.IsZero() || !accounts[0].Equals(allowed.wrapped) ||
+				!accounts[1].Equals(allowed.owner) || !accounts[2].Equals(allowed.owner) {
+				return fmt.Errorf("close account is not the wallet's wrapped SOL account closing to the wallet")
+			}
+		default:
+			return fmt.Errorf("token instruction %d is not allowed", data[0])
+		}
+
+	case solana.SPLAssociatedTokenAccountProgramID:
+		if len(data) > 0 && data[0] != ataCreate && data[0] != ataCreateIdempotent {
+			return fmt.Errorf("associated token account instruction %d is not allowed", data[0])
+		}
+		// Accounts: payer | associated account | wallet | mint | system program | token program
+		if len(accounts) < 4 || !accounts[0].Equals(allowed.owner) || !accounts[2].Equals(allowed.owner) ||
+			!allowed.mints[accounts[3]] || !allowed.tokenAccounts[accounts[1]] {
+			return fmt.Errorf("associated token account creation is not for the wallet and a quoted mint")
+		}
+	}
+
+	return nil
+}
+
+// accountKeys returns the transaction's static account keys followed by those loaded from
+// its address lookup tables, writable before read-only, in the order instructions index them
+func (c *Client) accountKeys(ctx context.Context, tx *solana.Transaction) (solana.PublicKeySlice, error) {
+	lookups := tx.Message.GetAddressTableLookups()
+	keys := append(solana.PublicKeySlice{}, tx.Message.AccountKeys...)
+	if len(lookups) == 0 {
+		return keys, nil
+	}
+
+	tables := make(map[solana.PublicKey]solana.PublicKeySlice, len(lookups))
+	for _, lookup := range lookups {
+		if _, ok := tables[lookup.AccountKey]; ok {
+			continue
+		}
+		table, err := addresslookuptable.GetAddressLookupTable(ctx, c.rpcClient, lookup.AccountKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load address lookup table %s: %w", lookup.AccountKey, err)
+		}
+		tables[lookup.AccountKey] = table.Addresses
+	}
+
+	var readonly solana.PublicKeySlice
+	for _, lookup := range lookups {
+		table := tables[lookup.AccountKey]
+		for _, index := range lookup.WritableIndexes {
+			if int(index) >= len(table) {
+				return nil, fmt.Errorf("address lookup table %s has no index %d", lookup.AccountKey, index)
+			}
+			keys = append(keys, table[index])
+		}
+		for _, index := range lookup.ReadonlyIndexes {
+			if int(index) >= len(table) {
+				return nil, fmt.Errorf("address lookup table %s has no index %d", lookup.AccountKey, index)
+			}
+			readonly = append(readonly, table[index])
+		}
+	}
+	return append(keys, readonly...), nil
+}
+
+// simulateAndVerify simulates the unsigned transaction and checks the wallet's resulting
+// balances against the quote: the input spent may not exceed the quote and the output
+// received may not fall below the slippage threshold
+func (c *Client) simulateAndVerify(ctx context.Context, tx *solana.Transaction, quote *Quote, owner solana.PublicKey, priorityFee uint64) (*rpc.SimulateTransactionResult, error) {
+	inputAccounts, err := balanceAccounts(owner, quote.InputMint)
+	if err != nil {
+		return nil, err
+	}
+	outputAccounts, err := balanceAccounts(owner, quote.OutputMint)
+	if err != nil {
+		return nil, err
+	}
+	addresses := append(append([]solana.PublicKey{}, inputAccounts...), outputAccounts...)
+
+	pre, err := c.rpcClient.GetMultipleAccountsWithOpts(ctx, addresses, &rpc.GetMultipleAccountsOpts{
+		Encoding:   solana.EncodingBase64,
+		Commitment: rpc.CommitmentConfirmed,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load pre-swap balances: %w", err)
+	}
+
+	sim, err := c.rpcClient.SimulateTransactionWithOpts(ctx, tx, &rpc.SimulateTransactionOpts{
+		SigVerify:              false,
+		ReplaceRecentBlockhash: true,
+		Commitment:             rpc.CommitmentConfirmed,
+		Accounts: &rpc.SimulateTransactionAccountsOpts{
+			Encoding:  solana.EncodingBase64,
+			Addresses: addresses,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to simulate swap: %w", err)
+	}
+	if sim.Value == nil {
+		return nil, fmt.Errorf("simulation returned no result")
+	}
+	if sim.Value.Err != nil {
+		utils.Debug("📜 Simulation Logs", "logs", sim.Value.Logs)
+		return sim.Value, fmt.Errorf("simulated swap failed: %v", sim.Value.Err)
+	}
+	if len(sim.Value.Accounts) != len(addresses) || len(pre.Value) != len(addresses) {
+		return nil, fmt.Errorf("simulation returned %d accounts, expected %d", len(sim.Value.Accounts), len(addresses))
+	}
+
+	n := len(inputAccounts)
+	spent := new(big.Int).Sub(
+		sumBalances(pre.Value[:n], quote.InputMint),
+		sumBalances(sim.Value.Accounts[:n], quote.InputMint),
+	)
+	received := new(big.Int).Sub(
+		sumBalances(sim.Value.Accounts[n:], quote.OutputMint),
+		sumBalances(pre.Value[n:], quote.OutputMint),
+	)
+
+	maxSpend, ok := new(big.Int).SetString(quote.InAmount, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid quote input amount %q", quote.InAmount)
+	}
+	if quote.InputMint == nativeMint {
+		maxSpend.Add(maxSpend, new(big.Int).SetUint64(maxSolOverheadLamports+priorityFee))
+	}
+
+	// A SOL output lands on the fee payer, whose balance also paid the transaction fee
+	if quote.OutputMint == nativeMint {
+		fee := lamportsPerSignature*uint64(tx.Message.Header.NumRequiredSignatures) + priorityFee
+		received.Add(received, new(big.Int).SetUint64(fee))
+	}
+
+	minReceive, ok := new(big.Int).SetString(quote.OtherAmountThreshold, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid quote threshold %q", quote.OtherAmountThreshold)
+	}
+
+	utils.Debug("🧪 Swap Simulation Checked",
+		"spent", spent.String(),
+		"max_spend", maxSpend.String(),
+		"received", received.String(),
+		"min_receive", minReceive.String())
+
+	if spent.Cmp(maxSpend) > 0 {
+		return nil, fmt.Errorf("simulated swap spends %s, quote allows at most %s", spent, maxSpend)
+	}
+	if received.Cmp(minReceive) < 0 {
+		return nil, fmt.Errorf("simulated swap receives %s, quote guarantees at least %s", received, minReceive)
+	}
+
+	return sim.Value, nil
+}
+
+// balanceAccounts returns the accounts holding the owner's balance of a mint.
+// SOL lives on the wallet itself; other mints may sit in a Token or Token-2022 ATA.
+func balanceAccounts(owner solana.PublicKey, mint string) ([]solana.PublicKey, error) {
+	if mint == nativeMint {
+		return []solana.PublicKey{owner}, nil
+	}
+
+	mintKey, err := solana.PublicKeyFromBase58(mint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mint %s: %w", mint, err)
+	}
+	return tokenAccounts(owner, mintKey)
+}
+
+// tokenAccounts derives the owner's associated token accounts of a mint under the Token
+// and Token-2022 programs, in that order
+func tokenAccounts(owner, mint solana.PublicKey) ([]solana.PublicKey, error) {
+	var accounts []solana.PublicKey
+	for _, program := range []solana.PublicKey{solana.TokenProgramID, solana.Token2022ProgramID} {
+		ata, _, err := solana.FindProgramAddress([][]byte{
+			owner[:],
+			program[:],
+			mint[:],
+		}, solana.SPLAssociatedTokenAccountProgramID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive token account: %w", err)
+		}
+		accounts = append(accounts, ata)
+	}
+	return accounts, nil
+}
+
+// sumBalances adds up the raw balance of mint across accounts; missing accounts count as zero
+func sumBalances(accounts []*rpc.Account, mint string) *big.Int {
+	total := new(big.Int)
+	for _, account := range accounts {
+		if account == nil {
+			continue
+		}
+		if mint == nativeMint {
+			total.Add(total, new(big.Int).SetUint64(account.Lamports))
+			continue
+		}
+		if account.Data == nil {
+			continue
+		}
+		// Token account layout: mint (32) | owner (32) | amount (8)
+		data := account.Data.GetBinary()
+		if len(data) < 72 {
+			continue
+		}
+		total.Add(total, new(big.Int).SetUint64(binary.LittleEndian.Uint64(data[64:72])))
+	}
+	return total
+}
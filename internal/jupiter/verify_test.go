@@ -0,0 +1,103 @@
+package jupiter
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+func TestVerifyInstruction(t *testing.T) {
+	owner := solana.NewWallet().PublicKey()
+	other := solana.NewWallet().PublicKey()
+	outputMint := solana.NewWallet().PublicKey()
+
+	allowed, err := newSwapAccounts(owner, &Quote{InputMint: nativeMint, OutputMint: outputMint.String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputAccounts, err := tokenAccounts(owner, outputMint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherAccounts, err := tokenAccounts(other, outputMint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrapped := allowed.wrapped
+	native := solana.MustPublicKeyFromBase58(nativeMint)
+
+	transfer := binary.LittleEndian.AppendUint64(binary.LittleEndian.AppendUint32(nil, systemTransfer), 1_000_000)
+	assign := binary.LittleEndian.AppendUint32(nil, 1)
+
+	tests := []struct {
+		name     string
+		program  solana.PublicKey
+		accounts []solana.PublicKey
+		data     []byte
+		wantErr  bool
+	}{
+		{"wrap SOL", solana.SystemProgramID, []solana.PublicKey{owner, wrapped}, transfer, false},
+		{"SOL to another account", solana.SystemProgramID, []solana.PublicKey{owner, other}, transfer, true},
+		{"other system instruction", solana.SystemProgramID, []solana.PublicKey{owner, wrapped}, assign, true},
+		{"sync native", solana.TokenProgramID, []solana.PublicKey{wrapped}, []byte{tokenSyncNative}, false},
+		{"sync another account", solana.TokenProgramID, []solana.PublicKey{outputAccounts[0]}, []byte{tokenSyncNative}, true},
+		{"unwrap SOL", solana.TokenProgramID, []solana.PublicKey{wrapped, owner, owner}, []byte{tokenCloseAccount}, false},
+		{"close to another wallet", solana.TokenProgramID, []solana.PublicKey{wrapped, other, owner}, []byte{tokenCloseAccount}, true},
+		{"close the output account", solana.Token2022ProgramID, []solana.PublicKey{outputAccounts[1], owner, owner}, []byte{tokenCloseAccount}, true},
+		{"token transfer", solana.TokenProgramID, []solana.PublicKey{outputAccounts[0], otherAccounts[0], owner}, []byte{3}, true},
+		{"approve", solana.Token2022ProgramID, []solana.PublicKey{outputAccounts[1], other, owner}, []byte{4}, true},
+		{"set authority", solana.TokenProgramID, []solana.PublicKey{outputAccounts[0], owner}, []byte{6}, true},
+		{
+			name:     "create the output account",
+			program:  solana.SPLAssociatedTokenAccountProgramID,
+			accounts: []solana.PublicKey{owner, outputAccounts[1], owner, outputMint, solana.SystemProgramID, solana.Token2022ProgramID},
+			data:     []byte{ataCreateIdempotent},
+		},
+		{
+			name:     "create the wrapped SOL account without data",
+			program:  solana.SPLAssociatedTokenAccountProgramID,
+			accounts: []solana.PublicKey{owner, wrapped, owner, native, solana.SystemProgramID, solana.TokenProgramID},
+		},
+		{
+			name:     "create an account for another wallet",
+			program:  solana.SPLAssociatedTokenAccountProgramID,
+			accounts: []solana.PublicKey{owner, otherAccounts[0], other, outputMint, solana.SystemProgramID, solana.TokenProgramID},
+			data:     []byte{ataCreateIdempotent},
+			wantErr:  true,
+		},
+		{
+			name:     "recover nested",
+			program:  solana.SPLAssociatedTokenAccountProgramID,
+			accounts: []solana.PublicKey{owner, outputAccounts[0], owner, outputMint, solana.SystemProgramID, solana.TokenProgramID},
+			data:     []byte{2},
+			wantErr:  true,
+		},
+		{"jupiter route", JupiterProgramID, []solana.PublicKey{owner, other}, []byte{1, 2, 3}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyInstruction(tt.program, tt.accounts, tt.data, allowed)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("verifyInstruction() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyInstructionWithoutSOL(t *testing.T) {
+	owner := solana.NewWallet().PublicKey()
+	allowed, err := newSwapAccounts(owner, &Quote{
+		InputMint:  solana.NewWallet().PublicKey().String(),
+		OutputMint: solana.NewWallet().PublicKey().String(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transfer := binary.LittleEndian.AppendUint32(nil, systemTransfer)
+	if err := verifyInstruction(solana.SystemProgramID, []solana.PublicKey{owner, solana.PublicKey{}}, transfer, allowed); err == nil {
+		t.Error("verifyInstruction() allowed a SOL transfer in a swap without SOL")
+	}
+}
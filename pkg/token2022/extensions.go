@@ -0,0 +1,290 @@
+package token2022
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Extension value sizes
+const (
+	mintCloseAuthoritySize       = 32
+	defaultAccountStateSize      = 1
+	transferHookSize             = 64
+	pointerSize                  = 64
+	tokenGroupSize               = 80
+	tokenGroupMemberSize         = 72
+	confidentialTransferMintSize = 65
+	scaledUiAmountSize           = 56
+	pausableSize                 = 33
+)
+
+// AccountState is the state new token accounts of a mint start in
+type AccountState string
+
+const (
+	AccountStateUninitialized AccountState = "uninitialized"
+	AccountStateInitialized   AccountState = "initialized"
+	AccountStateFrozen        AccountState = "frozen"
+)
+
+// TransferHook is a program invoked on every transfer of the mint
+type TransferHook struct {
+	Authority string `json:"authority,omitempty"`
+	ProgramID string `json:"program_id,omitempty"`
+}
+
+// Pointer points at the account holding a mint's metadata, group or group membership
+type Pointer struct {
+	Authority string `json:"authority,omitempty"`
+	Address   string `json:"address,omitempty"`
+}
+
+// TokenMetadata is the metadata stored in the mint itself
+type TokenMetadata struct {
+	UpdateAuthority    string            `json:"update_authority,omitempty"`
+	Mint               string            `json:"mint"`
+	Name               string            `json:"name"`
+	Symbol             string            `json:"symbol"`
+	URI                string            `json:"uri"`
+	AdditionalMetadata map[string]string `json:"additional_metadata,omitempty"`
+}
+
+// TokenGroup is a collection other mints can join as members
+type TokenGroup struct {
+	UpdateAuthority string `json:"update_authority,omitempty"`
+	Mint            string `json:"mint"`
+	Size            uint64 `json:"size"`
+	MaxSize         uint64 `json:"max_size"`
+}
+
+// TokenGroupMember is a mint's membership of a group
+type TokenGroupMember struct {
+	Mint         string `json:"mint"`
+	Group        string `json:"group"`
+	MemberNumber uint64 `json:"member_number"`
+}
+
+// ConfidentialTransferMint configures encrypted balances and transfers for the mint
+type ConfidentialTransferMint struct {
+	Authority               string `json:"authority,omitempty"`
+	AutoApproveNewAccounts  bool   `json:"auto_approve_new_accounts"`
+	AuditorElGamalPublicKey []byte `json:"auditor_elgamal_pubkey,omitempty"`
+}
+
+// ScaledUiAmount multiplies raw amounts into UI amounts, a staged multiplier takes over at its timestamp
+type ScaledUiAmount struct {
+	Authority                       string  `json:"authority,omitempty"`
+	Multiplier                      float64 `json:"multiplier"`
+	NewMultiplierEffectiveTimestamp int64   `json:"new_multiplier_effective_timestamp"`
+	NewMultiplier                   float64 `json:"new_multiplier"`
+}
+
+// Pausable lets an authority halt all transfers, mints and burns of the mint
+type Pausable struct {
+	Authority string `json:"authority,omitempty"`
+	Paused    bool   `json:"paused"`
+}
+
+// ActiveMultiplier returns the multiplier in force at now
+func (s *ScaledUiAmount) ActiveMultiplier(now time.Time) float64 {
+	if now.Unix() >= s.NewMultiplierEffectiveTimestamp {
+		return s.NewMultiplier
+	}
+	return s.Multiplier
+}
+
+// parseMintCloseAuthority decodes a MintCloseAuthority extension value, an all-zero key means none
+func (c *Client) parseMintCloseAuthority(ext Extension) (string, error) {
+	if err := requireLength(ext, mintCloseAuthoritySize); err != nil {
+		return "", err
+	}
+	return optionalPubkey(ext.Data), nil
+}
+
+// parseDefaultAccountState decodes a DefaultAccountState extension value, a single state byte
+func (c *Client) parseDefaultAccountState(ext Extension) (AccountState, error) {
+	if err := requireLength(ext, defaultAccountStateSize); err != nil {
+		return "", err
+	}
+
+	switch ext.Data[0] {
+	case 0:
+		return AccountStateUninitialized, nil
+	case 1:
+		return AccountStateInitialized, nil
+	case 2:
+		return AccountStateFrozen, nil
+	default:
+		return "", fmt.Errorf("invalid default account state %d", ext.Data[0])
+	}
+}
+
+// parseTransferHook decodes a TransferHook extension value: authority (32), program id (32)
+func (c *Client) parseTransferHook(ext Extension) (*TransferHook, error) {
+	if err := requireLength(ext, transferHookSize); err != nil {
+		return nil, err
+	}
+	return &TransferHook{
+		Authority: optionalPubkey(ext.Data[0:32]),
+		ProgramID: optionalPubkey(ext.Data[32:64]),
+	}, nil
+}
+
+// parsePointer decodes a MetadataPointer, GroupPointer or GroupMemberPointer extension value:
+// authority (32), address (32)
+func (c *Client) parsePointer(ext Extension) (*Pointer, error) {
+	if err := requireLength(ext, pointerSize); err != nil {
+		return nil, err
+	}
+	return &Pointer{
+		Authority: optionalPubkey(ext.Data[0:32]),
+		Address:   optionalPubkey(ext.Data[32:64]),
+	}, nil
+}
+
+// parseTokenMetadata decodes a TokenMetadata extension value, borsh encoded:
+// update authority (32), mint (32), name, symbol and uri (u32 length prefixed strings),
+// then additional metadata as a u32 count of key/value string pairs
+func (c *Client) parseTokenMetadata(ext Extension) (*TokenMetadata, error) {
+	if len(ext.Data) < 64 {
+		return nil, fmt.Errorf("%s extension is %d bytes, expected at least 64", ext.Type, len(ext.Data))
+	}
+
+	metadata := &TokenMetadata{
+		UpdateAuthority: optionalPubkey(ext.Data[0:32]),
+		Mint:            optionalPubkey(ext.Data[32:64]),
+	}
+
+	r := &borshReader{data: ext.Data[64:]}
+	metadata.Name = r.string()
+	metadata.Symbol = r.string()
+	metadata.URI = r.string()
+
+	count := r.u32()
+	if r.err == nil && count > 0 {
+		metadata.AdditionalMetadata = make(map[string]string)
+	}
+	for i := uint32(0); i < count && r.err == nil; i++ {
+		key := r.string()
+		metadata.AdditionalMetadata[key] = r.string()
+	}
+
+	if r.err != nil {
+		return nil, fmt.Errorf("invalid %s extension: %w", ext.Type, r.err)
+	}
+	return metadata, nil
+}
+
+// parseTokenGroup decodes a TokenGroup extension value:
+// update authority (32), mint (32), size (u64), max size (u64)
+func (c *Client) parseTokenGroup(ext Extension) (*TokenGroup, error) {
+	if err := requireLength(ext, tokenGroupSize); err != nil {
+		return nil, err
+	}
+	return &TokenGroup{
+		UpdateAuthority: optionalPubkey(ext.Data[0:32]),
+		Mint:            optionalPubkey(ext.Data[32:64]),
+		Size:            binary.LittleEndian.Uint64(ext.Data[64:72]),
+		MaxSize:         binary.LittleEndian.Uint64(ext.Data[72:80]),
+	}, nil
+}
+
+// parseTokenGroupMember decodes a TokenGroupMember extension value:
+// mint (32), group (32), member number (u64)
+func (c *Client) parseTokenGroupMember(ext Extension) (*TokenGroupMember, error) {
+	if err := requireLength(ext, tokenGroupMemberSize); err != nil {
+		return nil, err
+	}
+	return &TokenGroupMember{
+		Mint:         optionalPubkey(ext.Data[0:32]),
+		Group:        optionalPubkey(ext.Data[32:64]),
+		MemberNumber: binary.LittleEndian.Uint64(ext.Data[64:72]),
+	}, nil
+}
+
+// parseConfidentialTransferMint decodes a ConfidentialTransferMint extension value:
+// authority (32), auto approve new accounts (bool), auditor ElGamal public key (32, zeros when none)
+func (c *Client) parseConfidentialTransferMint(ext Extension) (*ConfidentialTransferMint, error) {
+	if err := requireLength(ext, confidentialTransferMintSize); err != nil {
+		return nil, err
+	}
+
+	mint := &ConfidentialTransferMint{
+		Authority:              optionalPubkey(ext.Data[0:32]),
+		AutoApproveNewAccounts: ext.Data[32] != 0,
+	}
+	if auditor := ext.Data[33:65]; !allZero(auditor) {
+		mint.AuditorElGamalPublicKey = append([]byte(nil), auditor...)
+	}
+	return mint, nil
+}
+
+// parseScaledUiAmount decodes a ScaledUiAmount extension value: authority (32),
+// multiplier (f64), new multiplier effective timestamp (i64), new multiplier (f64)
+func (c *Client) parseScaledUiAmount(ext Extension) (*ScaledUiAmount, error) {
+	if err := requireLength(ext, scaledUiAmountSize); err != nil {
+		return nil, err
+	}
+	return &ScaledUiAmount{
+		Authority:                       optionalPubkey(ext.Data[0:32]),
+		Multiplier:                      math.Float64frombits(binary.LittleEndian.Uint64(ext.Data[32:40])),
+		NewMultiplierEffectiveTimestamp: int64(binary.LittleEndian.Uint64(ext.Data[40:48])),
+		NewMultiplier:                   math.Float64frombits(binary.LittleEndian.Uint64(ext.Data[48:56])),
+	}, nil
+}
+
+// parsePausable decodes a Pausable extension value: authority (32), paused (bool)
+func (c *Client) parsePausable(ext Extension) (*Pausable, error) {
+	if err := requireLength(ext, pausableSize); err != nil {
+		return nil, err
+	}
+	return &Pausable{
+		Authority: optionalPubkey(ext.Data[0:32]),
+		Paused:    ext.Data[32] != 0,
+	}, nil
+}
+
+// borshReader reads length prefixed borsh values, keeping the first error
+type borshReader struct {
+	data []byte
+	err  error
+}
+
+func (r *borshReader) u32() uint32 {
+	if r.err != nil {
+		return 0
+	}
+	if len(r.data) < 4 {
+		r.err = fmt.Errorf("unexpected end of data")
+		return 0
+	}
+	v := binary.LittleEndian.Uint32(r.data[0:4])
+	r.data = r.data[4:]
+	return v
+}
+
+func (r *borshReader) string() string {
+	length := r.u32()
+	if r.err != nil {
+		return ""
+	}
+	if uint64(length) > uint64(len(r.data)) {
+		r.err = fmt.Errorf("string length %d exceeds remaining %d bytes", length, len(r.data))
+		return ""
+	}
+	s := string(r.data[:length])
+	r.data = r.data[length:]
+	return s
+}
+
+// allZero reports whether every byte is zero
+func allZero(data []byte) bool {
+	for _, b := range data {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,40 @@
+package token2022
+
+import "math/big"
+
+// maxFeeBasisPoints is 100%, the highest transfer fee the program allows
+const maxFeeBasisPoints = 10000
+
+// Active returns the fee schedule in force at an epoch
+func (f *TransferFee) Active(epoch uint64) FeeSchedule {
+	if epoch >= f.Newer.Epoch {
+		return f.Newer
+	}
+	return f.Older
+}
+
+// CalculateFee returns the raw fee withheld on a transfer of a raw amount at an epoch, the
+// same way the program does: basis points rounded up, capped at the maximum fee
+func (f *TransferFee) CalculateFee(amount, epoch uint64) uint64 {
+	if f == nil {
+		return 0
+	}
+	return f.Active(epoch).CalculateFee(amount)
+}
+
+// CalculateFee returns the raw fee this schedule withholds on a transfer of a raw amount
+func (s FeeSchedule) CalculateFee(amount uint64) uint64 {
+	if s.BasisPoints == 0 || amount == 0 {
+		return 0
+	}
+
+	// amount * bps can overflow 64 bits, the program computes it in 128
+	fee := new(big.Int).Mul(new(big.Int).SetUint64(amount), big.NewInt(int64(s.BasisPoints)))
+	fee.Add(fee, big.NewInt(maxFeeBasisPoints-1))
+	fee.Quo(fee, big.NewInt(maxFeeBasisPoints))
+
+	if !fee.IsUint64() || fee.Uint64() > s.MaximumFee {
+		return s.MaximumFee
+	}
+	return fee.Uint64()
+}
@@ -0,0 +1,151 @@
+package token2022
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+// Known answers from the Token-2022 program's transfer fee tests (calculate_fee_max,
+// calculate_fee_min and calculate_fee_zero), where one token at 1 bps is 10000 raw units
+func TestFeeScheduleCalculateFee(t *testing.T) {
+	const one = maxFeeBasisPoints
+	oneBps := FeeSchedule{MaximumFee: 5_000, BasisPoints: 1}
+
+	tests := []struct {
+		name     string
+		schedule FeeSchedule
+		amount   uint64
+		want     uint64
+	}{
+		// calculate_fee_max
+		{"max: u64 max hits the maximum", oneBps, math.MaxUint64, 5_000},
+		{"max: exactly at the maximum", oneBps, 5_000 * one, 5_000},
+		{"max: one above the maximum", oneBps, 5_000*one + 1, 5_000},
+		{"max: one below rounds up to the maximum", oneBps, 5_000*one - 1, 5_000},
+
+		// calculate_fee_min
+		{"min: one raw unit rounds up", oneBps, 1, 1},
+		{"min: zero is always zero", oneBps, 0, 0},
+		{"min: exactly at the minimum", oneBps, one, 1},
+		{"min: one above rounds up", oneBps, one + 1, 2},
+		{"min: one below rounds up to the minimum", oneBps, one - 1, 1},
+
+		// calculate_fee_zero
+		{"zero bps: zero", FeeSchedule{MaximumFee: math.MaxUint64}, 0, 0},
+		{"zero bps: u64 max", FeeSchedule{MaximumFee: math.MaxUint64}, math.MaxUint64, 0},
+		{"zero bps: one raw unit", FeeSchedule{MaximumFee: math.MaxUint64}, 1, 0},
+		{"zero bps: one token", FeeSchedule{MaximumFee: math.MaxUint64}, one, 0},
+		{"zero maximum: zero", FeeSchedule{BasisPoints: maxFeeBasisPoints}, 0, 0},
+		{"zero maximum: u64 max", FeeSchedule{BasisPoints: maxFeeBasisPoints}, math.MaxUint64, 0},
+		{"zero maximum: one raw unit", FeeSchedule{BasisPoints: maxFeeBasisPoints}, 1, 0},
+		{"zero maximum: one token", FeeSchedule{BasisPoints: maxFeeBasisPoints}, one, 0},
+
+		// Products beyond 64 bits
+		{"full fee on u64 max", FeeSchedule{MaximumFee: math.MaxUint64, BasisPoints: maxFeeBasisPoints}, math.MaxUint64, math.MaxUint64},
+		{"half fee on u64 max", FeeSchedule{MaximumFee: math.MaxUint64, BasisPoints: 5_000}, math.MaxUint64, math.MaxUint64/2 + 1},
+
+		{"1% rounds up", FeeSchedule{MaximumFee: math.MaxUint64, BasisPoints: 100}, 12_345, 124},
+		{"1% exact", FeeSchedule{MaximumFee: math.MaxUint64, BasisPoints: 100}, 12_300, 123},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.schedule.CalculateFee(tt.amount); got != tt.want {
+				t.Errorf("CalculateFee(%d) = %d, want %d", tt.amount, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransferFeeActive(t *testing.T) {
+	fee := &TransferFee{
+		Older: FeeSchedule{Epoch: 0, MaximumFee: 1_000, BasisPoints: 100},
+		Newer: FeeSchedule{Epoch: 500, MaximumFee: 1_000, BasisPoints: 250},
+	}
+
+	tests := []struct {
+		name   string
+		epoch  uint64
+		amount uint64
+		want   FeeSchedule
+		fee    uint64
+	}{
+		{"before the newer epoch", 499, 10_000, fee.Older, 100},
+		{"at the newer epoch", 500, 10_000, fee.Newer, 250},
+		{"after the newer epoch", 501, 10_000, fee.Newer, 250},
+		{"capped at the newer maximum", 600, 1_000_000, fee.Newer, 1_000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fee.Active(tt.epoch); got != tt.want {
+				t.Errorf("Active(%d) = %+v, want %+v", tt.epoch, got, tt.want)
+			}
+			if got := fee.CalculateFee(tt.amount, tt.epoch); got != tt.fee {
+				t.Errorf("CalculateFee(%d, %d) = %d, want %d", tt.amount, tt.epoch, got, tt.fee)
+			}
+		})
+	}
+}
+
+func TestTransferFeeCalculateFeeNil(t *testing.T) {
+	var fee *TransferFee
+	if got := fee.CalculateFee(1_000_000, 10); got != 0 {
+		t.Errorf("CalculateFee() on a mint without fees = %d, want 0", got)
+	}
+}
+
+func TestParseTransferFee(t *testing.T) {
+	schedule := func(epoch, maximum uint64, bps uint16) []byte {
+		data := make([]byte, 18)
+		binary.LittleEndian.PutUint64(data[0:8], epoch)
+		binary.LittleEndian.PutUint64(data[8:16], maximum)
+		binary.LittleEndian.PutUint16(data[16:18], bps)
+		return data
+	}
+
+	value := make([]byte, 0, transferFeeConfigSize)
+	value = append(value, make([]byte, 32)...) // No config authority
+	value = append(value, bytes.Repeat([]byte{1}, 32)...)
+	value = binary.LittleEndian.AppendUint64(value, 42)
+	value = append(value, schedule(10, 5_000, 50)...)
+	value = append(value, schedule(20, 9_000, 75)...)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    TransferFee
+		wantErr bool
+	}{
+		{
+			name: "both schedules",
+			data: value,
+			want: TransferFee{
+				WithdrawAuthority: optionalPubkey(bytes.Repeat([]byte{1}, 32)),
+				WithheldAmount:    42,
+				Older:             FeeSchedule{Epoch: 10, MaximumFee: 5_000, BasisPoints: 50},
+				Newer:             FeeSchedule{Epoch: 20, MaximumFee: 9_000, BasisPoints: 75},
+			},
+		},
+		{
+			name:    "truncated",
+			data:    value[:transferFeeConfigSize-1],
+			wantErr: true,
+		},
+	}
+
+	c := &Client{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.parseTransferFee(Extension{Type: ExtensionTransferFeeConfig, Data: tt.data})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTransferFee() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && *got != tt.want {
+				t.Errorf("parseTransferFee() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package token2022
+
+import (
+	"math"
+	"time"
+)
+
+// secondsPerYear is the year length the Token-2022 program accrues interest over
+const secondsPerYear = 60 * 60 * 24 * 365.24
+
+// EffectiveYield returns the annual yield in percent of a continuously compounded rate in basis points
+func EffectiveYield(rateBps int16) float64 {
+	return (math.Exp(float64(rateBps)/10000) - 1) * 100
+}
+
+// Scale returns the factor raw amounts have grown by through accrued interest at now, the
+// same way the program computes UI amounts
+func (r *InterestRate) Scale(now time.Time) float64 {
+	if r == nil {
+		return 1
+	}
+	preUpdate := accrual(r.PreUpdateAverageRate, r.LastUpdateTimestamp-r.InitializationTimestamp)
+	postUpdate := accrual(r.CurrentRate, now.Unix()-r.LastUpdateTimestamp)
+	return preUpdate * postUpdate
+}
+
+// UIAmount converts a raw amount to its UI amount including interest accrued up to now
+func (r *InterestRate) UIAmount(amount uint64, decimals int, now time.Time) float64 {
+	return float64(amount) * r.Scale(now) / math.Pow10(decimals)
+}
+
+// accrual returns the continuous growth of rateBps over seconds
+func accrual(rateBps int16, seconds int64) float64 {
+	return math.Exp(float64(rateBps) * float64(seconds) / secondsPerYear / 10000)
+}
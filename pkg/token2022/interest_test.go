@@ -0,0 +1,129 @@
+package token2022
+
+import (
+	"math"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// intSecondsPerYear is the year length the Token-2022 program's tests use
+const intSecondsPerYear = 6 * 6 * 24 * 36524
+
+func TestSecondsPerYear(t *testing.T) {
+	if secondsPerYear != 31_556_736 || intSecondsPerYear != 31_556_736 {
+		t.Errorf("seconds per year = %v, want 31556736", secondsPerYear)
+	}
+}
+
+// Known answers from the Token-2022 program's interest-bearing mint tests
+// (specific_amount_to_ui_amount), formatted the way the program formats UI amounts
+func TestInterestRateUIAmount(t *testing.T) {
+	constant5 := &InterestRate{
+		InitializationTimestamp: 0,
+		PreUpdateAverageRate:    500,
+		LastUpdateTimestamp:     intSecondsPerYear,
+		CurrentRate:             500,
+	}
+	negative5 := &InterestRate{
+		InitializationTimestamp: 0,
+		PreUpdateAverageRate:    -500,
+		LastUpdateTimestamp:     intSecondsPerYear,
+		CurrentRate:             -500,
+	}
+	netOut := &InterestRate{
+		InitializationTimestamp: 0,
+		PreUpdateAverageRate:    -500,
+		LastUpdateTimestamp:     intSecondsPerYear,
+		CurrentRate:             500,
+	}
+
+	tests := []struct {
+		name     string
+		rate     *InterestRate
+		amount   uint64
+		decimals int
+		now      int64
+		want     string
+	}{
+		{"one year at 5%", constant5, 1, 0, intSecondsPerYear, "1.0512710963760241"},
+		{"one year at 5% with 1 decimal", constant5, 1, 1, intSecondsPerYear, "0.10512710963760241"},
+		{"one year at 5%, huge amount with 10 decimals", constant5, 10_000_000_000, 10, intSecondsPerYear, "1.0512710963760241"},
+		{"one year at -5%", negative5, 1, 0, intSecondsPerYear, "0.951229424500714"},
+		{"-5% then 5% nets out", netOut, 1, 0, 2 * intSecondsPerYear, "1"},
+		{"u64 max over two years at 5%", constant5, math.MaxUint64, 0, 2 * intSecondsPerYear, "20386805083448100000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.rate.UIAmount(tt.amount, tt.decimals, time.Unix(tt.now, 0))
+			if s := strconv.FormatFloat(got, 'f', -1, 64); s != tt.want {
+				t.Errorf("UIAmount() = %s, want %s", s, tt.want)
+			}
+		})
+	}
+}
+
+func TestInterestRateScale(t *testing.T) {
+	tests := []struct {
+		name string
+		rate *InterestRate
+		now  int64
+		want float64
+	}{
+		{"no rate", nil, intSecondsPerYear, 1},
+		{"zero rate", &InterestRate{}, intSecondsPerYear, 1},
+		{"at initialization", &InterestRate{PreUpdateAverageRate: 500, CurrentRate: 500}, 0, 1},
+		{
+			name: "current rate only since the last update",
+			rate: &InterestRate{
+				InitializationTimestamp: 0,
+				PreUpdateAverageRate:    1000,
+				LastUpdateTimestamp:     intSecondsPerYear,
+				CurrentRate:             0,
+			},
+			now:  10 * intSecondsPerYear,
+			want: math.Exp(0.1),
+		},
+		{
+			name: "half a year at 10%",
+			rate: &InterestRate{
+				InitializationTimestamp: 100,
+				PreUpdateAverageRate:    1000,
+				LastUpdateTimestamp:     100,
+				CurrentRate:             1000,
+			},
+			now:  100 + intSecondsPerYear/2,
+			want: math.Exp(0.05),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.rate.Scale(time.Unix(tt.now, 0))
+			if math.Abs(got-tt.want) > 1e-15 {
+				t.Errorf("Scale() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEffectiveYield(t *testing.T) {
+	tests := []struct {
+		rate int16
+		want float64
+	}{
+		{0, 0},
+		{500, 5.127109637602403},
+		{-500, -4.877057549928599},
+		{10000, 171.82818284590452},
+	}
+
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(int(tt.rate)), func(t *testing.T) {
+			if got := EffectiveYield(tt.rate); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("EffectiveYield(%d) = %v, want %v", tt.rate, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package token2022
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/magooney-loon/token-2022-refill-bot/internal/utils"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// tokenMetadataDiscriminator prefixes TokenMetadata stored in an account other than the mint
+var tokenMetadataDiscriminator = []byte{112, 132, 90, 90, 11, 88, 157, 87}
+
+// mergeOnChainMetadata fills what Jupiter didn't provide from the mint's own metadata.
+// Jupiter data is kept when present; decimals are only taken from the mint when Jupiter
+// doesn't know the token at all.
+func (c *Client) mergeOnChainMetadata(ctx context.Context, info *TokenInfo, mint *rpc.Account, fromJupiter bool) error {
+	data := mint.Data.GetBinary()
+	if len(data) < MintAccountSize {
+		return fmt.Errorf("data too short for a mint")
+	}
+	if !fromJupiter {
+		info.Decimals = int(data[44])
+	}
+
+	metadata, source, err := c.onChainMetadata(ctx, info, fromJupiter)
+	if err != nil || metadata == nil {
+		return err
+	}
+
+	if info.Name == "" {
+		info.Name = metadata.Name
+	}
+	if info.Symbol == "" {
+		info.Symbol = metadata.Symbol
+	}
+	if info.URI == "" {
+		info.URI = metadata.URI
+	}
+
+	utils.Debug("🏷️ Merged On-Chain Metadata",
+		"token", info.Symbol,
+		"source", source,
+		"name", metadata.Name,
+		"uri", metadata.URI)
+	return nil
+}
+
+// onChainMetadata returns the mint's metadata and where it was read from. Token-2022 mints
+// point at it with the MetadataPointer extension, usually to the mint itself; classic SPL
+// mints keep it in their Metaplex metadata account. Jupiter already names the tokens it
+// knows, so other accounts are only fetched for tokens it named incompletely or not at all.
+func (c *Client) onChainMetadata(ctx context.Context, info *TokenInfo, fromJupiter bool) (*TokenMetadata, string, error) {
+	pointer := ""
+	if info.MetadataPointer != nil {
+		pointer = info.MetadataPointer.Address
+	}
+
+	if info.TokenMetadata != nil && (pointer == "" || pointer == info.Address) {
+		return info.TokenMetadata, "token_metadata", nil
+	}
+	if fromJupiter && info.Name != "" && info.Symbol != "" {
+		return nil, "", nil
+	}
+
+	if pointer != "" && pointer != info.Address {
+		address, err := solana.PublicKeyFromBase58(pointer)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid metadata pointer: %w", err)
+		}
+		account, err := c.fetchAccount(ctx, address)
+		if err != nil || account == nil {
+			return nil, "", err
+		}
+		if account.Owner.Equals(solana.TokenMetadataProgramID) {
+			metadata, err := c.parseMetaplexMetadata(account.Data.GetBinary())
+			return metadata, "metaplex", err
+		}
+		metadata, err := c.parseMetadataAccount(account.Data.GetBinary())
+		return metadata, "metadata_pointer", err
+	}
+
+	mintKey, err := solana.PublicKeyFromBase58(info.Address)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid token address: %w", err)
+	}
+	address, _, err := solana.FindTokenMetadataAddress(mintKey)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to derive metadata address: %w", err)
+	}
+	account, err := c.fetchAccount(ctx, address)
+	if err != nil || account == nil {
+		return nil, "", err
+	}
+	metadata, err := c.parseMetaplexMetadata(account.Data.GetBinary())
+	return metadata, "metaplex", err
+}
+
+// fetchAccount fetches an account, nil if it doesn't exist
+func (c *Client) fetchAccount(ctx context.Context, address solana.PublicKey) (*rpc.Account, error) {
+	account, err := c.rpcClient.GetAccountInfo(ctx, address)
+	if err != nil {
+		if errors.Is(err, rpc.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to fetch account %s: %w", address, err)
+	}
+	if account == nil || account.Value == nil {
+		return nil, nil
+	}
+	return account.Value, nil
+}
+
+// parseMetadataAccount decodes TokenMetadata kept outside the mint: an 8 byte discriminator,
+// a u32 length, then the same value the TokenMetadata extension holds
+func (c *Client) parseMetadataAccount(data []byte) (*TokenMetadata, error) {
+	if len(data) < 12 || !bytes.Equal(data[:8], tokenMetadataDiscriminator) {
+		return nil, fmt.Errorf("not a token metadata account")
+	}
+	length := binary.LittleEndian.Uint32(data[8:12])
+	if uint64(length) > uint64(len(data)-12) {
+		return nil, fmt.Errorf("token metadata length %d exceeds remaining %d bytes", length, len(data)-12)
+	}
+
+	return c.parseTokenMetadata(Extension{
+		Type: ExtensionTokenMetadata,
+		Data: data[12 : 12+length],
+	})
+}
+
+// parseMetaplexMetadata decodes the start of a Metaplex metadata account, borsh encoded:
+// key (u8), update authority (32), mint (32), then name, symbol and uri padded with zeros
+func (c *Client) parseMetaplexMetadata(data []byte) (*TokenMetadata, error) {
+	if len(data) < 65 {
+		return nil, fmt.Errorf("data too short for metaplex metadata")
+	}
+
+	r := &borshReader{data: data[65:]}
+	metadata := &TokenMetadata{
+		UpdateAuthority: optionalPubkey(data[1:33]),
+		Mint:            optionalPubkey(data[33:65]),
+		Name:            strings.TrimRight(r.string(), "\x00"),
+		Symbol:          strings.TrimRight(r.string(), "\x00"),
+		URI:             strings.TrimRight(r.string(), "\x00"),
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("invalid metaplex metadata: %w", r.err)
+	}
+	return metadata, nil
+}
@@ -0,0 +1,134 @@
+package token2022
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// ExtensionType identifies a Token-2022 extension in the TLV list
+type ExtensionType uint16
+
+// Account types stored right after the base account data
+const (
+	AccountTypeUninitialized = 0
+	AccountTypeMint          = 1
+	AccountTypeAccount       = 2
+)
+
+const (
+	// TokenAccountSize is the size of a base token account; mints with extensions are
+	// padded to it so the account type byte sits at the same offset for both
+	TokenAccountSize = 165
+
+	// AccountTypeOffset is where the account type byte is stored
+	AccountTypeOffset = TokenAccountSize
+
+	// tlvHeaderSize is the type (u16) and length (u16) preceding every extension value
+	tlvHeaderSize = 4
+)
+
+// Extension is one TLV entry with its raw value
+type Extension struct {
+	Type ExtensionType
+	Data []byte
+}
+
+// MarshalText renders the extension by name in JSON
+func (t ExtensionType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// String returns the extension's name as used by the Token-2022 program
+func (t ExtensionType) String() string {
+	if name, ok := extensionNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown(%d)", uint16(t))
+}
+
+var extensionNames = map[ExtensionType]string{
+	ExtensionUninitialized:                 "uninitialized",
+	ExtensionTransferFeeConfig:             "transfer_fee_config",
+	ExtensionTransferFeeAmount:             "transfer_fee_amount",
+	ExtensionMintCloseAuthority:            "mint_close_authority",
+	ExtensionConfidentialTransferMint:      "confidential_transfer_mint",
+	ExtensionConfidentialTransferAccount:   "confidential_transfer_account",
+	ExtensionDefaultAccountState:           "default_account_state",
+	ExtensionImmutableOwner:                "immutable_owner",
+	ExtensionMemoTransfer:                  "memo_transfer",
+	ExtensionNonTransferable:               "non_transferable",
+	ExtensionInterestBearingConfig:         "interest_bearing_config",
+	ExtensionCpiGuard:                      "cpi_guard",
+	ExtensionPermanentDelegate:             "permanent_delegate",
+	ExtensionNonTransferableAccount:        "non_transferable_account",
+	ExtensionTransferHook:                  "transfer_hook",
+	ExtensionTransferHookAccount:           "transfer_hook_account",
+	ExtensionConfidentialTransferFeeConfig: "confidential_transfer_fee_config",
+	ExtensionConfidentialTransferFeeAmount: "confidential_transfer_fee_amount",
+	ExtensionMetadataPointer:               "metadata_pointer",
+	ExtensionTokenMetadata:                 "token_metadata",
+	ExtensionGroupPointer:                  "group_pointer",
+	ExtensionTokenGroup:                    "token_group",
+	ExtensionGroupMemberPointer:            "group_member_pointer",
+	ExtensionTokenGroupMember:              "token_group_member",
+	ExtensionConfidentialMintBurn:          "confidential_mint_burn",
+	ExtensionScaledUiAmount:                "scaled_ui_amount",
+	ExtensionPausable:                      "pausable",
+	ExtensionPausableAccount:               "pausable_account",
+}
+
+// ParseExtensions walks the TLV list of a Token-2022 mint or token account. Accounts without
+// extensions have none; a malformed list is an error rather than a partial result.
+func ParseExtensions(data []byte) ([]Extension, error) {
+	if len(data) <= TokenAccountSize {
+		return nil, nil
+	}
+
+	switch data[AccountTypeOffset] {
+	case AccountTypeMint:
+		// The mint is padded with zeros up to the account type byte
+		if !allZero(data[MintAccountSize:AccountTypeOffset]) {
+			return nil, fmt.Errorf("mint padding is not zeroed")
+		}
+	case AccountTypeAccount:
+	default:
+		return nil, fmt.Errorf("invalid account type %d", data[AccountTypeOffset])
+	}
+
+	var extensions []Extension
+	seen := make(map[ExtensionType]bool)
+	rest := data[AccountTypeOffset+1:]
+	for len(rest) >= tlvHeaderSize {
+		extType := ExtensionType(binary.LittleEndian.Uint16(rest[0:2]))
+		length := int(binary.LittleEndian.Uint16(rest[2:4]))
+
+		// Space reserved for extensions not written yet reads as uninitialized
+		if extType == ExtensionUninitialized {
+			break
+		}
+		if length > len(rest)-tlvHeaderSize {
+			return nil, fmt.Errorf("extension %s length %d exceeds remaining %d bytes",
+				extType, length, len(rest)-tlvHeaderSize)
+		}
+		if seen[extType] {
+			return nil, fmt.Errorf("duplicate extension %s", extType)
+		}
+		seen[extType] = true
+
+		extensions = append(extensions, Extension{
+			Type: extType,
+			Data: rest[tlvHeaderSize : tlvHeaderSize+length],
+		})
+		rest = rest[tlvHeaderSize+length:]
+	}
+
+	return extensions, nil
+}
+
+// requireLength checks a fixed-size extension value
+func requireLength(ext Extension, size int) error {
+	if len(ext.Data) != size {
+		return fmt.Errorf("%s extension is %d bytes, expected %d", ext.Type, len(ext.Data), size)
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package token2022
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// tlv encodes one extension entry
+func tlv(extType ExtensionType, value []byte) []byte {
+	entry := make([]byte, tlvHeaderSize, tlvHeaderSize+len(value))
+	binary.LittleEndian.PutUint16(entry[0:2], uint16(extType))
+	binary.LittleEndian.PutUint16(entry[2:4], uint16(len(value)))
+	return append(entry, value...)
+}
+
+// account builds account data of an account type followed by TLV entries
+func account(accountType byte, entries ...[]byte) []byte {
+	data := make([]byte, TokenAccountSize, TokenAccountSize+1)
+	data = append(data, accountType)
+	for _, entry := range entries {
+		data = append(data, entry...)
+	}
+	return data
+}
+
+func TestParseExtensions(t *testing.T) {
+	closeAuthority := bytes.Repeat([]byte{7}, mintCloseAuthoritySize)
+	paused := append(bytes.Repeat([]byte{9}, 32), 1)
+
+	dirtyPadding := account(AccountTypeMint, tlv(ExtensionNonTransferable, nil))
+	dirtyPadding[MintAccountSize] = 1
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    []Extension
+		wantErr bool
+	}{
+		{
+			name: "base mint without extensions",
+			data: make([]byte, MintAccountSize),
+		},
+		{
+			name: "base token account without extensions",
+			data: make([]byte, TokenAccountSize),
+		},
+		{
+			name: "single extension",
+			data: account(AccountTypeMint, tlv(ExtensionMintCloseAuthority, closeAuthority)),
+			want: []Extension{{Type: ExtensionMintCloseAuthority, Data: closeAuthority}},
+		},
+		{
+			name: "several extensions in order",
+			data: account(AccountTypeMint,
+				tlv(ExtensionMintCloseAuthority, closeAuthority),
+				tlv(ExtensionNonTransferable, nil),
+				tlv(ExtensionPausable, paused)),
+			want: []Extension{
+				{Type: ExtensionMintCloseAuthority, Data: closeAuthority},
+				{Type: ExtensionNonTransferable, Data: []byte{}},
+				{Type: ExtensionPausable, Data: paused},
+			},
+		},
+		{
+			name: "token account extensions",
+			data: account(AccountTypeAccount, tlv(ExtensionImmutableOwner, nil)),
+			want: []Extension{{Type: ExtensionImmutableOwner, Data: []byte{}}},
+		},
+		{
+			name: "stops at uninitialized space",
+			data: account(AccountTypeMint,
+				tlv(ExtensionNonTransferable, nil),
+				make([]byte, 16),
+				tlv(ExtensionPausable, paused)),
+			want: []Extension{{Type: ExtensionNonTransferable, Data: []byte{}}},
+		},
+		{
+			name: "ignores a trailing partial header",
+			data: append(account(AccountTypeMint, tlv(ExtensionNonTransferable, nil)), 3, 0),
+			want: []Extension{{Type: ExtensionNonTransferable, Data: []byte{}}},
+		},
+		{
+			name: "type byte only",
+			data: account(AccountTypeMint),
+		},
+		{
+			name:    "invalid account type",
+			data:    account(3, tlv(ExtensionNonTransferable, nil)),
+			wantErr: true,
+		},
+		{
+			name:    "uninitialized account type",
+			data:    account(AccountTypeUninitialized, tlv(ExtensionNonTransferable, nil)),
+			wantErr: true,
+		},
+		{
+			name:    "mint padding not zeroed",
+			data:    dirtyPadding,
+			wantErr: true,
+		},
+		{
+			name:    "length beyond the data",
+			data:    account(AccountTypeMint, tlv(ExtensionMintCloseAuthority, closeAuthority)[:20]),
+			wantErr: true,
+		},
+		{
+			name: "duplicate extension",
+			data: account(AccountTypeMint,
+				tlv(ExtensionNonTransferable, nil),
+				tlv(ExtensionNonTransferable, nil)),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseExtensions(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseExtensions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParseExtensions() returned %d extensions, want %d: %v", len(got), len(tt.want), got)
+			}
+			for i := range got {
+				if got[i].Type != tt.want[i].Type || !bytes.Equal(got[i].Data, tt.want[i].Data) {
+					t.Errorf("extension %d = %s %x, want %s %x", i, got[i].Type, got[i].Data, tt.want[i].Type, tt.want[i].Data)
+				}
+			}
+		})
+	}
+}
+
+func TestRequireLength(t *testing.T) {
+	tests := []struct {
+		name    string
+		ext     Extension
+		size    int
+		wantErr bool
+	}{
+		{"exact", Extension{Type: ExtensionPausable, Data: make([]byte, pausableSize)}, pausableSize, false},
+		{"short", Extension{Type: ExtensionPausable, Data: make([]byte, pausableSize-1)}, pausableSize, true},
+		{"long", Extension{Type: ExtensionPausable, Data: make([]byte, pausableSize+1)}, pausableSize, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := requireLength(tt.ext, tt.size); (err != nil) != tt.wantErr {
+				t.Errorf("requireLength() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExtensionTypeString(t *testing.T) {
+	tests := []struct {
+		extType ExtensionType
+		want    string
+	}{
+		{ExtensionTransferFeeConfig, "transfer_fee_config"},
+		{ExtensionInterestBearingConfig, "interest_bearing_config"},
+		{ExtensionTokenMetadata, "token_metadata"},
+		{ExtensionPausableAccount, "pausable_account"},
+		{ExtensionType(999), "unknown(999)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := tt.extType.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+			text, err := tt.extType.MarshalText()
+			if err != nil || string(text) != tt.want {
+				t.Errorf("MarshalText() = %q, %v, want %q", text, err, tt.want)
+			}
+		})
+	}
+}
@@ -23,34 +23,74 @@ type TokenInfo struct {
 	Symbol      string   `json:"symbol"`
 	Decimals    int      `json:"decimals"`
 	LogoURI     string   `json:"logoURI"`
+	URI         string   `json:"uri,omitempty"` // Off-chain metadata JSON, from on-chain metadata
 	Tags        []string `json:"tags"`
 	DailyVolume float64  `json:"daily_volume"`
 	CreatedAt   string   `json:"created_at"`
 	MintedAt    string   `json:"minted_at"`
 
+	// Mint authorities
+	FreezeAuthority *string `json:"freeze_authority"`
+	MintAuthority   *string `json:"mint_authority"`
+
 	// Token-2022 Extensions
-	TransferFee       *TransferFee  `json:"transfer_fee"`
-	InterestRate      *InterestRate `json:"interest_rate"`
-	PermanentDelegate *string       `json:"permanent_delegate"`
-	FreezeAuthority   *string       `json:"freeze_authority"`
-	MintAuthority     *string       `json:"mint_authority"`
-
-	// Additional metadata
-	Extensions map[string]interface{} `json:"extensions"`
+	Extensions           []ExtensionType           `json:"token_extensions"` // Every extension on the mint, "extensions" is Jupiter's
+	TransferFee          *TransferFee              `json:"transfer_fee"`
+	InterestRate         *InterestRate             `json:"interest_rate"`
+	PermanentDelegate    *string                   `json:"permanent_delegate"`
+	MintCloseAuthority   *string                   `json:"mint_close_authority,omitempty"`
+	DefaultAccountState  AccountState              `json:"default_account_state,omitempty"`
+	NonTransferable      bool                      `json:"non_transferable,omitempty"`
+	TransferHook         *TransferHook             `json:"transfer_hook,omitempty"`
+	MetadataPointer      *Pointer                  `json:"metadata_pointer,omitempty"`
+	TokenMetadata        *TokenMetadata            `json:"token_metadata,omitempty"`
+	GroupPointer         *Pointer                  `json:"group_pointer,omitempty"`
+	TokenGroup           *TokenGroup               `json:"token_group,omitempty"`
+	GroupMemberPointer   *Pointer                  `json:"group_member_pointer,omitempty"`
+	TokenGroupMember     *TokenGroupMember         `json:"token_group_member,omitempty"`
+	ConfidentialTransfer *ConfidentialTransferMint `json:"confidential_transfer,omitempty"`
+	ScaledUiAmount       *ScaledUiAmount           `json:"scaled_ui_amount,omitempty"`
+	Pausable             *Pausable                 `json:"pausable,omitempty"`
+	Epoch                uint64                    `json:"epoch"` // Epoch the extensions were read at
+}
+
+// HasExtension reports whether the mint carries an extension
+func (t *TokenInfo) HasExtension(extType ExtensionType) bool {
+	for _, ext := range t.Extensions {
+		if ext == extType {
+			return true
+		}
+	}
+	return false
 }
 
-// TransferFee represents token transfer fee configuration
+// TransferFee represents token transfer fee configuration. A fee change is staged as the
+// newer fee and takes over from the older one at its epoch.
 type TransferFee struct {
-	BasisPoints     uint16           `json:"basis_points"`
-	MaximumFee      uint64           `json:"maximum_fee"`
-	CollectorWallet solana.PublicKey `json:"collector_wallet"`
+	ConfigAuthority   string      `json:"config_authority,omitempty"`
+	WithdrawAuthority string      `json:"withdraw_authority,omitempty"`
+	WithheldAmount    uint64      `json:"withheld_amount"` // Raw fees withheld on the mint itself
+	Older             FeeSchedule `json:"older"`
+	Newer             FeeSchedule `json:"newer"`
+}
+
+// FeeSchedule is a transfer fee that applies from its epoch on
+type FeeSchedule struct {
+	Epoch       uint64 `json:"epoch"`
+	MaximumFee  uint64 `json:"maximum_fee"` // Raw units
+	BasisPoints uint16 `json:"basis_points"`
 }
 
-// InterestRate represents token interest/dividend configuration
+// InterestRate represents an interest-bearing mint's configuration. Interest accrues
+// continuously: at the pre-update average rate from initialization to the last rate update,
+// and at the current rate since. Rates are in basis points and may be negative.
 type InterestRate struct {
-	CurrentRate    uint16  `json:"current_rate"`
-	APY            float64 `json:"apy"`
-	LastUpdateSlot uint64  `json:"last_update_slot"`
+	RateAuthority           string  `json:"rate_authority,omitempty"`
+	InitializationTimestamp int64   `json:"initialization_timestamp"`
+	PreUpdateAverageRate    int16   `json:"pre_update_average_rate"`
+	LastUpdateTimestamp     int64   `json:"last_update_timestamp"`
+	CurrentRate             int16   `json:"current_rate"`
+	APY                     float64 `json:"apy"` // Effective annual yield of the current rate, in percent
 }
 
 // TokenCache provides thread-safe caching of token information
@@ -90,7 +130,8 @@ func NewClient(cfg *config.Config, rpcClient *rpc.Client) *Client {
 	}
 }
 
-// GetTokenInfo fetches combined token information
+// GetTokenInfo fetches combined token information. Mints the Jupiter token API doesn't know
+// are described from their on-chain metadata instead.
 func (c *Client) GetTokenInfo(ctx context.Context, address string) (*TokenInfo, error) {
 	// Check cache first
 	if !c.config.Token.RefreshCache {
@@ -107,33 +148,35 @@ func (c *Client) GetTokenInfo(ctx context.Context, address string) (*TokenInfo,
 		"address", fmt.Sprintf("%s...%s", address[:8], address[len(address)-8:]),
 		"endpoint", c.config.Jupiter.TokenAPIEndpoint)
 
-	// Fetch from Jupiter API
-	url := fmt.Sprintf("%s/%s", c.config.Jupiter.TokenAPIEndpoint, address)
-	resp, err := c.httpClient.Get(url)
-	if err != nil {
-		utils.Error("❌ Failed to fetch token info", err,
-			"address", address)
-		return nil, fmt.Errorf("failed to fetch token info: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		utils.Error("❌ Jupiter API Error", fmt.Errorf("status: %d", resp.StatusCode),
+	info, jupiterErr := c.fetchJupiterInfo(address)
+	if jupiterErr != nil {
+		utils.Warn("⚠️ Token unknown to Jupiter, reading on-chain metadata",
+			"error", jupiterErr,
 			"address", address)
-		return nil, fmt.Errorf("API error: %d", resp.StatusCode)
-	}
-
-	var info TokenInfo
-	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
-		utils.Error("❌ Failed to decode token info", err)
-		return nil, fmt.Errorf("failed to decode token info: %w", err)
+		info = &TokenInfo{Address: address}
 	}
 
-	// Fetch Token-2022 extensions if available
-	if err := c.enrichWithToken2022Data(ctx, &info); err != nil {
+	mint, err := c.fetchMint(ctx, address)
+	if err != nil {
+		// Without Jupiter the mint itself is the only source of decimals
+		if jupiterErr != nil {
+			return nil, fmt.Errorf("failed to fetch token info: %w (on chain: %v)", jupiterErr, err)
+		}
 		utils.Warn("⚠️ Failed to fetch Token-2022 data",
 			"error", err,
 			"address", address)
+	} else {
+		// Fetch Token-2022 extensions if available
+		if err := c.enrichWithToken2022Data(ctx, info, mint); err != nil {
+			utils.Warn("⚠️ Failed to fetch Token-2022 data",
+				"error", err,
+				"address", address)
+		}
+		if err := c.mergeOnChainMetadata(ctx, info, mint, jupiterErr == nil); err != nil {
+			utils.Warn("⚠️ Failed to read on-chain metadata",
+				"error", err,
+				"address", address)
+		}
 	}
 
 	utils.Info("✅ Token Info Retrieved",
@@ -143,87 +186,272 @@ func (c *Client) GetTokenInfo(ctx context.Context, address string) (*TokenInfo,
 		"tags", info.Tags)
 
 	// Cache the result
-	c.cache.Set(address, &info)
+	c.cache.Set(address, info)
 	utils.Debug("📦 Token Info Cached",
 		"address", fmt.Sprintf("%s...%s", address[:8], address[len(address)-8:]),
 		"ttl", fmt.Sprintf("%d minutes", c.config.Token.CacheTTLMinutes))
 
-	return &info, nil
+	return info, nil
 }
 
-// GetTransferFeeBps returns the transfer fee in basis points
-func (t *TokenInfo) GetTransferFeeBps() uint16 {
-	if t.TransferFee != nil {
-		return t.TransferFee.BasisPoints
+// fetchJupiterInfo fetches token information from the Jupiter token API
+func (c *Client) fetchJupiterInfo(address string) (*TokenInfo, error) {
+	url := fmt.Sprintf("%s/%s", c.config.Jupiter.TokenAPIEndpoint, address)
+	resp, err := c.httpClient.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch token info: %w", err)
 	}
-	return 0
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API error: %d", resp.StatusCode)
+	}
+
+	var info *TokenInfo
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, fmt.Errorf("failed to decode token info: %w", err)
+	}
+
+	// Unknown mints come back as null
+	if info == nil || info.Address == "" {
+		return nil, fmt.Errorf("token not found")
+	}
+	return info, nil
 }
 
-// enrichWithToken2022Data fetches and adds Token-2022 specific data
-func (c *Client) enrichWithToken2022Data(ctx context.Context, info *TokenInfo) error {
-	pubkey, err := solana.PublicKeyFromBase58(info.Address)
+// fetchMint fetches the mint account
+func (c *Client) fetchMint(ctx context.Context, address string) (*rpc.Account, error) {
+	pubkey, err := solana.PublicKeyFromBase58(address)
 	if err != nil {
-		return fmt.Errorf("invalid token address: %w", err)
+		return nil, fmt.Errorf("invalid token address: %w", err)
 	}
 
-	// Fetch account info
 	account, err := c.rpcClient.GetAccountInfo(ctx, pubkey)
 	if err != nil {
-		return fmt.Errorf("failed to fetch account info: %w", err)
+		return nil, fmt.Errorf("failed to fetch account info: %w", err)
 	}
 
 	if account == nil || account.Value == nil {
-		return fmt.Errorf("account not found")
+		return nil, fmt.Errorf("account not found")
 	}
+	return account.Value, nil
+}
 
-	// Parse Token-2022 extensions
-	info.Extensions = make(map[string]interface{})
-
-	// Check for transfer fee extension
-	if transferFee, err := c.parseTransferFee(account.Value.Data.GetBinary()); err == nil {
-		info.TransferFee = transferFee
-		info.Extensions["transfer_fee"] = true
-		utils.Debug("📊 Found Transfer Fee Extension",
-			"token", info.Symbol,
-			"bps", transferFee.BasisPoints,
-			"max_fee", transferFee.MaximumFee)
+// GetTransferFeeBps returns the transfer fee in basis points at an epoch
+func (t *TokenInfo) GetTransferFeeBps(epoch uint64) uint16 {
+	if t.TransferFee != nil {
+		return t.TransferFee.Active(epoch).BasisPoints
 	}
+	return 0
+}
 
-	// Check for interest rate extension
-	if interestRate, err := c.parseInterestRate(account.Value.Data.GetBinary()); err == nil {
-		info.InterestRate = interestRate
-		info.Extensions["interest_rate"] = true
-		utils.Debug("📈 Found Interest Rate Extension",
-			"token", info.Symbol,
-			"rate", interestRate.CurrentRate,
-			"apy", interestRate.APY)
+// CurrentEpoch returns the cluster's current epoch
+func (c *Client) CurrentEpoch(ctx context.Context) (uint64, error) {
+	info, err := c.rpcClient.GetEpochInfo(ctx, rpc.CommitmentConfirmed)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get epoch info: %w", err)
 	}
+	return info.Epoch, nil
+}
 
-	// Check for permanent delegate
-	if delegate, err := c.parsePermanentDelegate(account.Value.Data.GetBinary()); err == nil {
-		info.PermanentDelegate = &delegate
-		info.Extensions["permanent_delegate"] = true
-		utils.Debug("👥 Found Permanent Delegate",
-			"token", info.Symbol,
-			"delegate", delegate)
-	}
+// enrichWithToken2022Data adds the authorities and Token-2022 extensions of a mint account
+func (c *Client) enrichWithToken2022Data(ctx context.Context, info *TokenInfo, mint *rpc.Account) error {
+	data := mint.Data.GetBinary()
 
 	// Check for authorities
-	if auth, err := c.parseAuthorities(account.Value.Data.GetBinary()); err == nil {
+	if auth, err := c.parseAuthorities(data); err == nil {
 		if auth.FreezeAuthority != "" {
 			info.FreezeAuthority = &auth.FreezeAuthority
-			info.Extensions["freeze_authority"] = true
 		}
 		if auth.MintAuthority != "" {
 			info.MintAuthority = &auth.MintAuthority
-			info.Extensions["mint_authority"] = true
 		}
-		utils.Debug("🔑 Found Authority Extensions",
+		utils.Debug("🔑 Found Mint Authorities",
 			"token", info.Symbol,
 			"freeze_auth", auth.FreezeAuthority != "",
 			"mint_auth", auth.MintAuthority != "")
 	}
 
+	// Classic SPL mints carry no extensions
+	if !mint.Owner.Equals(solana.Token2022ProgramID) {
+		return nil
+	}
+
+	extensions, err := ParseExtensions(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse extensions: %w", err)
+	}
+
+	info.Extensions = make([]ExtensionType, 0, len(extensions))
+	for _, ext := range extensions {
+		info.Extensions = append(info.Extensions, ext.Type)
+		if err := c.decodeExtension(ctx, info, ext); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// decodeExtension sets the TokenInfo field of a mint extension
+func (c *Client) decodeExtension(ctx context.Context, info *TokenInfo, ext Extension) error {
+	switch ext.Type {
+	case ExtensionTransferFeeConfig:
+		transferFee, err := c.parseTransferFee(ext)
+		if err != nil {
+			return err
+		}
+		info.TransferFee = transferFee
+
+		epoch, err := c.CurrentEpoch(ctx)
+		if err != nil {
+			return err
+		}
+		info.Epoch = epoch
+
+		active := transferFee.Active(epoch)
+		utils.Debug("📊 Found Transfer Fee Extension",
+			"token", info.Symbol,
+			"epoch", epoch,
+			"bps", active.BasisPoints,
+			"max_fee", active.MaximumFee,
+			"newer_bps", transferFee.Newer.BasisPoints,
+			"newer_epoch", transferFee.Newer.Epoch)
+
+	case ExtensionInterestBearingConfig:
+		interestRate, err := c.parseInterestRate(ext)
+		if err != nil {
+			return err
+		}
+		info.InterestRate = interestRate
+		utils.Debug("📈 Found Interest Rate Extension",
+			"token", info.Symbol,
+			"rate", interestRate.CurrentRate,
+			"average_rate", interestRate.PreUpdateAverageRate,
+			"apy", interestRate.APY)
+
+	case ExtensionPermanentDelegate:
+		delegate, err := c.parsePermanentDelegate(ext)
+		if err != nil {
+			return err
+		}
+		if delegate != "" {
+			info.PermanentDelegate = &delegate
+			utils.Debug("👥 Found Permanent Delegate",
+				"token", info.Symbol,
+				"delegate", delegate)
+		}
+
+	case ExtensionMintCloseAuthority:
+		authority, err := c.parseMintCloseAuthority(ext)
+		if err != nil {
+			return err
+		}
+		if authority != "" {
+			info.MintCloseAuthority = &authority
+		}
+
+	case ExtensionDefaultAccountState:
+		state, err := c.parseDefaultAccountState(ext)
+		if err != nil {
+			return err
+		}
+		info.DefaultAccountState = state
+
+	case ExtensionNonTransferable:
+		info.NonTransferable = true
+
+	case ExtensionTransferHook:
+		hook, err := c.parseTransferHook(ext)
+		if err != nil {
+			return err
+		}
+		info.TransferHook = hook
+		utils.Debug("🪝 Found Transfer Hook",
+			"token", info.Symbol,
+			"program", hook.ProgramID)
+
+	case ExtensionMetadataPointer:
+		pointer, err := c.parsePointer(ext)
+		if err != nil {
+			return err
+		}
+		info.MetadataPointer = pointer
+
+	case ExtensionTokenMetadata:
+		metadata, err := c.parseTokenMetadata(ext)
+		if err != nil {
+			return err
+		}
+		info.TokenMetadata = metadata
+		utils.Debug("🏷️ Found Token Metadata",
+			"token", info.Symbol,
+			"name", metadata.Name,
+			"symbol", metadata.Symbol)
+
+	case ExtensionGroupPointer:
+		pointer, err := c.parsePointer(ext)
+		if err != nil {
+			return err
+		}
+		info.GroupPointer = pointer
+
+	case ExtensionTokenGroup:
+		group, err := c.parseTokenGroup(ext)
+		if err != nil {
+			return err
+		}
+		info.TokenGroup = group
+
+	case ExtensionGroupMemberPointer:
+		pointer, err := c.parsePointer(ext)
+		if err != nil {
+			return err
+		}
+		info.GroupMemberPointer = pointer
+
+	case ExtensionTokenGroupMember:
+		member, err := c.parseTokenGroupMember(ext)
+		if err != nil {
+			return err
+		}
+		info.TokenGroupMember = member
+
+	case ExtensionConfidentialTransferMint:
+		confidential, err := c.parseConfidentialTransferMint(ext)
+		if err != nil {
+			return err
+		}
+		info.ConfidentialTransfer = confidential
+
+	case ExtensionScaledUiAmount:
+		scaled, err := c.parseScaledUiAmount(ext)
+		if err != nil {
+			return err
+		}
+		info.ScaledUiAmount = scaled
+		utils.Debug("📐 Found Scaled UI Amount",
+			"token", info.Symbol,
+			"multiplier", scaled.ActiveMultiplier(time.Now()))
+
+	case ExtensionPausable:
+		pausable, err := c.parsePausable(ext)
+		if err != nil {
+			return err
+		}
+		info.Pausable = pausable
+		if pausable.Paused {
+			utils.Warn("⏸️ Token is paused",
+				"token", info.Symbol)
+		}
+
+	default:
+		utils.Debug("🧩 Found Extension",
+			"token", info.Symbol,
+			"type", ext.Type.String(),
+			"size", len(ext.Data))
+	}
+
 	return nil
 }
 
@@ -7,19 +7,46 @@ import (
 	"github.com/gagliardetto/solana-go"
 )
 
+// Extension type identifiers, as numbered by the Token-2022 program
 const (
-	// Extension type identifiers
-	ExtensionTransferFee           = 1
-	ExtensionInterestBearing       = 2
-	ExtensionPermanentDelegate     = 3
-	ExtensionConfidentialTransfers = 4
-	ExtensionDefaultAccountState   = 5
-	ExtensionMemoTransfer          = 6
-	ExtensionNonTransferable       = 7
-	ExtensionInterestBearingConfig = 8
+	ExtensionUninitialized                 ExtensionType = 0
+	ExtensionTransferFeeConfig             ExtensionType = 1
+	ExtensionTransferFeeAmount             ExtensionType = 2
+	ExtensionMintCloseAuthority            ExtensionType = 3
+	ExtensionConfidentialTransferMint      ExtensionType = 4
+	ExtensionConfidentialTransferAccount   ExtensionType = 5
+	ExtensionDefaultAccountState           ExtensionType = 6
+	ExtensionImmutableOwner                ExtensionType = 7
+	ExtensionMemoTransfer                  ExtensionType = 8
+	ExtensionNonTransferable               ExtensionType = 9
+	ExtensionInterestBearingConfig         ExtensionType = 10
+	ExtensionCpiGuard                      ExtensionType = 11
+	ExtensionPermanentDelegate             ExtensionType = 12
+	ExtensionNonTransferableAccount        ExtensionType = 13
+	ExtensionTransferHook                  ExtensionType = 14
+	ExtensionTransferHookAccount           ExtensionType = 15
+	ExtensionConfidentialTransferFeeConfig ExtensionType = 16
+	ExtensionConfidentialTransferFeeAmount ExtensionType = 17
+	ExtensionMetadataPointer               ExtensionType = 18
+	ExtensionTokenMetadata                 ExtensionType = 19
+	ExtensionGroupPointer                  ExtensionType = 20
+	ExtensionTokenGroup                    ExtensionType = 21
+	ExtensionGroupMemberPointer            ExtensionType = 22
+	ExtensionTokenGroupMember              ExtensionType = 23
+	ExtensionConfidentialMintBurn          ExtensionType = 24
+	ExtensionScaledUiAmount                ExtensionType = 25
+	ExtensionPausable                      ExtensionType = 26
+	ExtensionPausableAccount               ExtensionType = 27
+)
 
+const (
 	// Base account data size
 	MintAccountSize = 82
+
+	// Extension value sizes
+	transferFeeConfigSize     = 108
+	interestBearingConfigSize = 52
+	permanentDelegateSize     = 32
 )
 
 type authorities struct {
@@ -27,91 +54,85 @@ type authorities struct {
 	MintAuthority   string
 }
 
-// parseTransferFee parses the transfer fee extension data
-func (c *Client) parseTransferFee(data []byte) (*TransferFee, error) {
-	if len(data) < MintAccountSize+16 {
-		return nil, fmt.Errorf("data too short for transfer fee")
-	}
-
-	// Skip base mint data
-	data = data[MintAccountSize:]
-
-	// Check extension type
-	if binary.LittleEndian.Uint16(data) != ExtensionTransferFee {
-		return nil, fmt.Errorf("not a transfer fee extension")
+// parseTransferFee decodes a TransferFeeConfig extension value:
+// config authority (32), withdraw authority (32), withheld amount (u64),
+// then the older and newer fees as epoch (u64), maximum fee (u64), basis points (u16)
+func (c *Client) parseTransferFee(ext Extension) (*TransferFee, error) {
+	if err := requireLength(ext, transferFeeConfigSize); err != nil {
+		return nil, err
 	}
 
-	// Parse transfer fee data
-	basisPoints := binary.LittleEndian.Uint16(data[2:])
-	maximumFee := binary.LittleEndian.Uint64(data[4:])
-	collector := solana.PublicKeyFromBytes(data[12:44])
-
 	return &TransferFee{
-		BasisPoints:     basisPoints,
-		MaximumFee:      maximumFee,
-		CollectorWallet: collector,
+		ConfigAuthority:   optionalPubkey(ext.Data[0:32]),
+		WithdrawAuthority: optionalPubkey(ext.Data[32:64]),
+		WithheldAmount:    binary.LittleEndian.Uint64(ext.Data[64:72]),
+		Older:             parseFeeSchedule(ext.Data[72:90]),
+		Newer:             parseFeeSchedule(ext.Data[90:108]),
 	}, nil
 }
 
-// parseInterestRate parses the interest rate extension data
-func (c *Client) parseInterestRate(data []byte) (*InterestRate, error) {
-	if len(data) < MintAccountSize+24 {
-		return nil, fmt.Errorf("data too short for interest rate")
+// parseFeeSchedule decodes one transfer fee: epoch (u64), maximum fee (u64), basis points (u16)
+func parseFeeSchedule(data []byte) FeeSchedule {
+	return FeeSchedule{
+		Epoch:       binary.LittleEndian.Uint64(data[0:8]),
+		MaximumFee:  binary.LittleEndian.Uint64(data[8:16]),
+		BasisPoints: binary.LittleEndian.Uint16(data[16:18]),
 	}
+}
 
-	// Skip base mint data
-	data = data[MintAccountSize:]
-
-	// Check extension type
-	if binary.LittleEndian.Uint16(data) != ExtensionInterestBearing {
-		return nil, fmt.Errorf("not an interest bearing extension")
+// parseInterestRate decodes an InterestBearingConfig extension value:
+// rate authority (32), initialization timestamp (i64), pre-update average rate (i16),
+// last update timestamp (i64), current rate (i16)
+func (c *Client) parseInterestRate(ext Extension) (*InterestRate, error) {
+	if err := requireLength(ext, interestBearingConfigSize); err != nil {
+		return nil, err
 	}
 
-	// Parse interest rate data
-	currentRate := binary.LittleEndian.Uint16(data[2:])
-	apy := float64(currentRate) / 100.0 // Convert basis points to percentage
-	lastUpdateSlot := binary.LittleEndian.Uint64(data[16:])
-
+	currentRate := int16(binary.LittleEndian.Uint16(ext.Data[50:52]))
 	return &InterestRate{
-		CurrentRate:    currentRate,
-		APY:            apy,
-		LastUpdateSlot: lastUpdateSlot,
+		RateAuthority:           optionalPubkey(ext.Data[0:32]),
+		InitializationTimestamp: int64(binary.LittleEndian.Uint64(ext.Data[32:40])),
+		PreUpdateAverageRate:    int16(binary.LittleEndian.Uint16(ext.Data[40:42])),
+		LastUpdateTimestamp:     int64(binary.LittleEndian.Uint64(ext.Data[42:50])),
+		CurrentRate:             currentRate,
+		APY:                     EffectiveYield(currentRate),
 	}, nil
 }
 
-// parsePermanentDelegate parses the permanent delegate extension data
-func (c *Client) parsePermanentDelegate(data []byte) (string, error) {
-	if len(data) < MintAccountSize+34 {
-		return "", fmt.Errorf("data too short for permanent delegate")
+// parsePermanentDelegate decodes a PermanentDelegate extension value, an all-zero key means none
+func (c *Client) parsePermanentDelegate(ext Extension) (string, error) {
+	if err := requireLength(ext, permanentDelegateSize); err != nil {
+		return "", err
 	}
-
-	// Skip base mint data
-	data = data[MintAccountSize:]
-
-	// Check extension type
-	if binary.LittleEndian.Uint16(data) != ExtensionPermanentDelegate {
-		return "", fmt.Errorf("not a permanent delegate extension")
-	}
-
-	// Parse delegate pubkey
-	delegate := solana.PublicKeyFromBytes(data[2:34])
-	return delegate.String(), nil
+	return optionalPubkey(ext.Data), nil
 }
 
-// parseAuthorities parses the mint and freeze authorities
+// parseAuthorities parses the mint and freeze authorities from the base mint data. Both are
+// a COption: a u32 tag followed by the key. Mint authority sits at offset 0, freeze authority at 46.
 func (c *Client) parseAuthorities(data []byte) (*authorities, error) {
 	if len(data) < MintAccountSize {
 		return nil, fmt.Errorf("data too short for authorities")
 	}
 
-	// Authorities are in the base mint data
-	// Mint authority is at offset 0
-	// Freeze authority is at offset 36
-	mintAuth := solana.PublicKeyFromBytes(data[0:32])
-	freezeAuth := solana.PublicKeyFromBytes(data[36:68])
-
 	return &authorities{
-		MintAuthority:   mintAuth.String(),
-		FreezeAuthority: freezeAuth.String(),
+		MintAuthority:   coptionPubkey(data[0:36]),
+		FreezeAuthority: coptionPubkey(data[46:82]),
 	}, nil
 }
+
+// coptionPubkey returns the key of a COption<Pubkey>, empty when none is set
+func coptionPubkey(data []byte) string {
+	if binary.LittleEndian.Uint32(data[0:4]) == 0 {
+		return ""
+	}
+	return solana.PublicKeyFromBytes(data[4:36]).String()
+}
+
+// optionalPubkey returns the key of an OptionalNonZeroPubkey, empty when all zeros
+func optionalPubkey(data []byte) string {
+	key := solana.PublicKeyFromBytes(data[:32])
+	if key.IsZero() {
+		return ""
+	}
+	return key.String()
+}
@@ -7,6 +7,7 @@ import (
 	"math"
 	"net/http"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -28,6 +29,7 @@ type TokenBalance struct {
 	IsInput   bool
 	IsOutput  bool
 	IsToken22 bool
+	Accrued   float64 // Interest accrued on top of the raw amount, interest-bearing mints only
 	TokenInfo *token2022.TokenInfo
 }
 
@@ -46,11 +48,15 @@ type PortfolioToken struct {
 	TokenBalance
 	USDValue     float64
 	Distribution float64
+	AccruedUSD   float64
+	APY          float64 // Effective annual yield of interest-bearing mints, in percent
 }
 
 type Portfolio struct {
-	Tokens        []PortfolioToken
-	TotalUSDValue float64
+	Tokens         []PortfolioToken
+	TotalUSDValue  float64
+	AccruedUSD     float64 // Interest accrued on interest-bearing holdings
+	EffectiveYield float64 // Annual yield of the whole portfolio from interest, in percent
 }
 
 // formatAmount formats a number with k/m/b suffixes
@@ -150,7 +156,7 @@ func GetWalletBalances(ctx context.Context, cfg *config.Config, rpcClient *rpc.C
 		Decimals: 9,
 		UiAmount: formatAmount(solAmount),
 		IsInput:  cfg.Token.InputMint == "So11111111111111111111111111111111111111112",
-		IsOutput: cfg.Token.OutputMint == "So11111111111111111111111111111111111111112",
+		IsOutput: cfg.Token.IsTarget("So11111111111111111111111111111111111111112"),
 	})
 
 	// Process token accounts
@@ -214,16 +220,31 @@ func GetWalletBalances(ctx context.Context, cfg *config.Config, rpcClient *rpc.C
 			}
 		}
 
+		// Interest-bearing mints accrue on top of the raw amount
+		uiAmount := *tokenBalance.Value.UiAmount
+		accrued := 0.0
+		if tokenInfo != nil && tokenInfo.InterestRate != nil {
+			raw, err := strconv.ParseUint(tokenBalance.Value.Amount, 10, 64)
+			if err != nil {
+				utils.Debug("Invalid raw token amount", "mint", mint, "amount", tokenBalance.Value.Amount)
+				continue
+			}
+			decimals := int(tokenBalance.Value.Decimals)
+			uiAmount = tokenInfo.InterestRate.UIAmount(raw, decimals, time.Now())
+			accrued = uiAmount - float64(raw)/math.Pow10(decimals)
+		}
+
 		balance := TokenBalance{
 			Symbol:    symbol,
 			Name:      name,
 			Mint:      mint,
-			Balance:   *tokenBalance.Value.UiAmount,
+			Balance:   uiAmount,
 			Decimals:  uint8(tokenBalance.Value.Decimals),
-			UiAmount:  formatAmount(*tokenBalance.Value.UiAmount),
+			UiAmount:  formatAmount(uiAmount),
 			IsInput:   mint == cfg.Token.InputMint,
-			IsOutput:  mint == cfg.Token.OutputMint,
+			IsOutput:  cfg.Token.IsTarget(mint),
 			IsToken22: isToken2022,
+			Accrued:   accrued,
 			TokenInfo: tokenInfo,
 		}
 
@@ -308,14 +329,20 @@ func CalculatePortfolio(balances []TokenBalance, prices map[string]float64) Port
 		pToken := PortfolioToken{
 			TokenBalance: bal,
 			USDValue:     usdValue,
+			AccruedUSD:   bal.Accrued * price,
+		}
+		if bal.TokenInfo != nil && bal.TokenInfo.InterestRate != nil {
+			pToken.APY = bal.TokenInfo.InterestRate.APY
 		}
+		portfolio.AccruedUSD += pToken.AccruedUSD
 		portfolio.Tokens = append(portfolio.Tokens, pToken)
 	}
 
-	// Calculate distribution
+	// Calculate distribution, the portfolio yields each token's APY on its share
 	for i := range portfolio.Tokens {
 		if portfolio.TotalUSDValue > 0 {
 			portfolio.Tokens[i].Distribution = (portfolio.Tokens[i].USDValue / portfolio.TotalUSDValue) * 100
+			portfolio.EffectiveYield += portfolio.Tokens[i].APY * portfolio.Tokens[i].Distribution / 100
 		}
 	}
 
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"time"
 
 	"github.com/magooney-loon/token-2022-refill-bot/internal/config"
@@ -39,6 +40,13 @@ type DividendTransfer struct {
 	Timestamp   time.Time `json:"timestamp"`
 	PreBalance  float64   `json:"pre_balance"`
 	PostBalance float64   `json:"post_balance"`
+
+	// Buys made with this payout by the dividend trigger. Large buys are split orders whose
+	// slices land later, their amount is included in the bought amount.
+	Handled       bool     `json:"handled,omitempty"`
+	BuySignatures []string `json:"buy_signatures,omitempty"`
+	BuyOrders     []string `json:"buy_orders,omitempty"`
+	BoughtAmount  float64  `json:"bought_amount,omitempty"`
 }
 
 func loadDividendCache(wallet string) (*DividendCache, error) {
@@ -81,6 +89,48 @@ func saveDividendCache(wallet string, cache *DividendCache) error {
 	return os.WriteFile(cacheFile, data, 0644)
 }
 
+// DividendHandled reports whether the dividend trigger already acted on a payout
+func DividendHandled(wallet, signature string) (bool, error) {
+	cache, err := loadDividendCache(wallet)
+	if err != nil {
+		return false, err
+	}
+	transfer, ok := cache.Transactions[signature]
+	return ok && transfer.Handled, nil
+}
+
+// RecordDividendBuy stores a payout together with the buys made from it
+func RecordDividendBuy(wallet string, transfer DividendTransfer, buySignatures []string, bought float64) error {
+	cache, err := loadDividendCache(wallet)
+	if err != nil {
+		return err
+	}
+
+	transfer.Handled = true
+	transfer.BuySignatures = buySignatures
+	transfer.BoughtAmount = bought
+	cache.Transactions[transfer.Signature] = &transfer
+
+	return saveDividendCache(wallet, cache)
+}
+
+// GetDividendTransfers returns the cached dividend transfers of a wallet, oldest first
+func GetDividendTransfers(wallet string) ([]DividendTransfer, error) {
+	cache, err := loadDividendCache(wallet)
+	if err != nil {
+		return nil, err
+	}
+
+	transfers := make([]DividendTransfer, 0, len(cache.Transactions))
+	for _, transfer := range cache.Transactions {
+		transfers = append(transfers, *transfer)
+	}
+	sort.Slice(transfers, func(i, j int) bool {
+		return transfers[i].Timestamp.Before(transfers[j].Timestamp)
+	})
+	return transfers, nil
+}
+
 // GetDividendHistory fetches all transfers from dividend address to user wallet
 func GetDividendHistory(ctx context.Context, cfg *config.Config, rpcClient *rpc.Client, tokenClient *token2022.Client, wallet string) (*DividendInfo, error) {
 	start := time.Now()
@@ -182,7 +232,7 @@ func GetDividendHistory(ctx context.Context, cfg *config.Config, rpcClient *rpc.
 		}
 
 		// Find wallet index and check dividend
-		amount := getTransferAmount(tx, cfg.Token.DividendMint, wallet)
+		amount := GetTransferAmount(tx, cfg.Token.DividendMint, wallet)
 		if amount <= 0 {
 			continue
 		}
@@ -247,8 +297,8 @@ func GetDividendHistory(ctx context.Context, cfg *config.Config, rpcClient *rpc.
 	return info, nil
 }
 
-// Helper to get transfer amount from transaction
-func getTransferAmount(tx *rpc.GetTransactionResult, dividendAddress, wallet string) float64 {
+// GetTransferAmount returns the SOL the wallet received in a transaction involving the dividend address
+func GetTransferAmount(tx *rpc.GetTransactionResult, dividendAddress, wallet string) float64 {
 	if tx == nil || tx.Meta == nil {
 		return 0
 	}
@@ -43,6 +43,9 @@ func DisplayWalletBalances(ctx context.Context, cfg *config.Config, rpcClient *r
 
 	// Display portfolio summary
 	fmt.Printf("\n💰 Portfolio Value: $%.2f\n", portfolio.TotalUSDValue)
+	if portfolio.EffectiveYield != 0 {
+		fmt.Printf("📈 Effective Yield: %.2f%% APY | Accrued Interest: $%.2f\n", portfolio.EffectiveYield, portfolio.AccruedUSD)
+	}
 	fmt.Println("-------------------")
 
 	// Display balances with portfolio info
@@ -80,10 +83,19 @@ func displayTokenInfo(token PortfolioToken, showRole bool) {
 	programType := "SPL"
 	if token.TokenInfo != nil {
 		if token.TokenInfo.TransferFee != nil {
-			tokenInfo += fmt.Sprintf(" | Fee: %.2f%%", float64(token.TokenInfo.TransferFee.BasisPoints)/100)
+			tokenInfo += fmt.Sprintf(" | Fee: %.2f%%", float64(token.TokenInfo.GetTransferFeeBps(token.TokenInfo.Epoch))/100)
 		}
 		if token.TokenInfo.InterestRate != nil {
-			tokenInfo += fmt.Sprintf(" | APY: %.2f%%", token.TokenInfo.InterestRate.APY)
+			tokenInfo += fmt.Sprintf(" | APY: %.2f%% | Accrued: %s", token.APY, formatAmount(token.Accrued))
+		}
+		if token.TokenInfo.Pausable != nil && token.TokenInfo.Pausable.Paused {
+			tokenInfo += " | ⏸️ Paused"
+		}
+		if token.TokenInfo.NonTransferable {
+			tokenInfo += " | Non-transferable"
+		}
+		if token.TokenInfo.TransferHook != nil && token.TokenInfo.TransferHook.ProgramID != "" {
+			tokenInfo += " | Transfer hook"
 		}
 	}
 	if token.IsToken22 {